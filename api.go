@@ -10,6 +10,17 @@ import (
 type ApiParams map[string]interface{}
 
 func (bot *Bot) CallApi(action string, params ApiParams) (*gjson.Result, error) {
+	ret, err := bot.callApi(action, params)
+	if err == nil {
+		bot.recordSentMessage(action, ret)
+	}
+	if bot.stats != nil {
+		bot.stats.recordApiCall(err)
+	}
+	return ret, err
+}
+
+func (bot *Bot) callApi(action string, params ApiParams) (*gjson.Result, error) {
 	log.Infof("正在调用接口%s", action)
 	rsp, err := bot.provider.Request(action, params)
 	if err != nil {
@@ -43,11 +54,19 @@ func (bot *Bot) CallApi(action string, params ApiParams) (*gjson.Result, error)
 	}
 }
 
+// 按配置中的消息格式编码消息
+func (bot *Bot) encodeMessage(message Message) interface{} {
+	if cfg := bot.config.load(); cfg != nil && cfg.GetBaseConfig().MessageFormat == MessageFormat_Array {
+		return message
+	}
+	return message.String()
+}
+
 // 发送私聊消息
 func (bot *Bot) SendPrivateMsg(userId int64, message Message, autoEscape bool) (int32, error) {
 	data, err := bot.CallApi("send_private_msg", ApiParams{
 		"user_id":     userId,
-		"message":     message.String(),
+		"message":     bot.encodeMessage(message),
 		"auto_escape": autoEscape,
 	})
 	if err != nil {
@@ -61,7 +80,7 @@ func (bot *Bot) SendPrivateMsg(userId int64, message Message, autoEscape bool) (
 func (bot *Bot) SendGroupMsg(groupId int64, message Message, autoEscape bool) (int32, error) {
 	data, err := bot.CallApi("send_group_msg", ApiParams{
 		"group_id":    groupId,
-		"message":     message.String(),
+		"message":     bot.encodeMessage(message),
 		"auto_escape": autoEscape,
 	})
 	if err != nil {
@@ -71,11 +90,24 @@ func (bot *Bot) SendGroupMsg(groupId int64, message Message, autoEscape bool) (i
 	return messageId, nil
 }
 
+// 发送频道消息（go-cqhttp扩展）。返回的消息ID为字符串
+func (bot *Bot) SendGuildChannelMsg(guildId, channelId string, message Message) (string, error) {
+	data, err := bot.CallApi("send_guild_channel_msg", ApiParams{
+		"guild_id":   guildId,
+		"channel_id": channelId,
+		"message":    bot.encodeMessage(message),
+	})
+	if err != nil {
+		return "", err
+	}
+	return data.Get("message_id").String(), nil
+}
+
 // 发送消息
 func (bot *Bot) SendMsg(messageType string, userId, groupId int64, message Message, autoEscape bool) (int32, error) {
 	params := ApiParams{
 		"message_type": messageType,
-		"message":      message.String(),
+		"message":      bot.encodeMessage(message),
 		"auto_escape":  autoEscape,
 	}
 	switch messageType {
@@ -140,7 +172,7 @@ func (bot *Bot) GetMsg(messageId int32) (*ApiResponse_GetMsg, error) {
 		MessageType: data.Get("message_type").String(),
 		MessageId:   int32(data.Get("message_id").Int()),
 		RealId:      int32(data.Get("real_id").Int()),
-		Message:     ConvertJsonArrayToMessage(data.Get("message").Array()),
+		Message:     ConvertJsonToMessage(data.Get("message")),
 	}
 	sender := MessageEventSender{
 		UserId:   data.Get("user_id").Int(),
@@ -175,10 +207,54 @@ func (bot *Bot) GetForwardMsg(messageId int32) (*Message, error) {
 	if err != nil {
 		return nil, err
 	}
-	ret := ConvertJsonArrayToMessage(data.Get("message").Array())
+	ret := ConvertJsonToMessage(data.Get("message"))
+	return &ret, nil
+}
+
+// 获取合并转发内容。id为收到的forward消息段中的ID
+func (bot *Bot) GetForwardMsgById(id string) (*Message, error) {
+	data, err := bot.CallApi("get_forward_msg", ApiParams{
+		"message_id": id,
+		"id":         id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 新版本go-cqhttp返回的字段为messages
+	content := data.Get("message")
+	if !content.Exists() {
+		content = data.Get("messages")
+	}
+	ret := ConvertJsonToMessage(content)
 	return &ret, nil
 }
 
+// 发送合并转发（群聊）。messages为由node消息段组成的消息
+func (bot *Bot) SendGroupForwardMsg(groupId int64, messages Message) (int32, error) {
+	data, err := bot.CallApi("send_group_forward_msg", ApiParams{
+		"group_id": groupId,
+		"messages": messages,
+	})
+	if err != nil {
+		return -1, err
+	}
+	messageId := int32(data.Get("message_id").Int())
+	return messageId, nil
+}
+
+// 发送合并转发（好友）。messages为由node消息段组成的消息
+func (bot *Bot) SendPrivateForwardMsg(userId int64, messages Message) (int32, error) {
+	data, err := bot.CallApi("send_private_forward_msg", ApiParams{
+		"user_id":  userId,
+		"messages": messages,
+	})
+	if err != nil {
+		return -1, err
+	}
+	messageId := int32(data.Get("message_id").Int())
+	return messageId, nil
+}
+
 // 发送好友赞
 func (bot *Bot) SendLike(userId int64, times int) error {
 	_, err := bot.CallApi("send_like", ApiParams{
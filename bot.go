@@ -1,15 +1,52 @@
 package gonebot
 
+import "sync/atomic"
+
 type Bot struct {
+	*botState
+	stats *pluginStats // 插件使用的Bot才有，统计插件调用API的情况
+}
+
+// 同一个Engine的所有Bot共享的状态
+type botState struct {
 	provider Provider
+	config   *configHolder
+	recalls  *recallScheduler // 等待定时撤回的消息
+
+	sentMessages  *messageIdCache // 最近发出的消息
+	otherMessages *messageIdCache // 查询过的、不是bot发出的消息
 
 	selfId int64
+	online atomic.Bool
 }
 
 func (bot *Bot) Init(provider Provider) {
+	if bot.botState == nil {
+		bot.botState = &botState{}
+	}
 	bot.provider = provider
+	bot.recalls = newRecallScheduler(bot)
+	bot.sentMessages = newMessageIdCache()
+	bot.otherMessages = newMessageIdCache()
+}
+
+// 给插件使用的Bot，与原Bot共享状态，调用API时计入插件的统计
+func (bot *Bot) forPlugin(stats *pluginStats) *Bot {
+	if bot == nil {
+		return nil
+	}
+	return &Bot{botState: bot.botState, stats: stats}
 }
 
 func (bot *Bot) GetSelfId() int64 {
 	return bot.selfId
 }
+
+// 是否在线。连接上协议端或收到心跳后为true，心跳丢失后为false
+func (bot *Bot) IsOnline() bool {
+	return bot.online.Load()
+}
+
+func (bot *Bot) setOnline(online bool) {
+	bot.online.Store(online)
+}
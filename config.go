@@ -13,11 +13,20 @@ type Config interface {
 	GetBaseConfig() *BaseConfig
 }
 
+// 发送消息时使用的消息格式
+type MessageFormat string
+
+const (
+	MessageFormat_String MessageFormat = "string" // 字符串格式（CQ码）
+	MessageFormat_Array  MessageFormat = "array"  // 数组格式（消息段数组）
+)
+
 type PluginConfigMap map[string]interface{}   // 插件配置
 type ProviderConfigMap map[string]interface{} // 服务提供者配置
 type BaseConfig struct {
 	// Websocket WebsocketConfig `yaml:"websocket"`
 	CmdPrefix      []string `yaml:"cmd_prefix"`      // 命令前缀
+	Nickname       []string `yaml:"nickname"`        // bot的昵称，以昵称开头的消息视为与bot有关
 	Superuser      []int64  `yaml:"superuser"`       // 超级用户
 	ApiCallTimeout int      `yaml:"apicall_timeout"` // API调用超时时间，单位：秒
 	Plugin         struct {
@@ -26,6 +35,26 @@ type BaseConfig struct {
 	} `yaml:"plugin"`
 	Provider       string                       `yaml:"provider"`
 	ProviderConfig map[string]ProviderConfigMap `yaml:"provider_config"`
+	LongMessage    LongMessageOptions           `yaml:"long_message"`   // 长消息的分割与发送方式
+	MessageFormat  MessageFormat                `yaml:"message_format"` // 调用API发送消息时使用的消息格式，默认string
+
+	// 退出时仍在等待撤回的消息保存到该文件，下次启动后继续撤回。不填则在退出时立即撤回
+	RecallPersistFile string `yaml:"recall_persist_file"`
+
+	// 连续多少个心跳间隔未收到心跳时判定为掉线，默认3，小于0时不检测
+	HeartbeatTimeout int `yaml:"heartbeat_timeout"`
+
+	EventFilter EventFilterConfig `yaml:"event_filter"` // 全局的事件过滤
+
+	PluginSwitch PluginSwitchConfig `yaml:"plugin_switch"` // 按群、按私聊开关插件
+
+	HotReload bool `yaml:"hot_reload"` // 配置文件修改后自动重新载入
+
+	Storage StorageConfig `yaml:"storage"` // 插件的键值存储
+
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // 插件多次panic或超时后暂停处理事件
+
+	path string // 配置文件的路径，由LoadConfig、LoadCustomConfig记录
 }
 
 func (mp ProviderConfigMap) DecodeTo(v interface{}) error {
@@ -69,6 +98,7 @@ func LoadConfig(path string) *BaseConfig {
 		panic(err)
 	}
 
+	cfg.path = path
 	return &cfg
 }
 
@@ -86,4 +116,5 @@ func LoadCustomConfig(path string, cfgPtr Config) {
 		err = fmt.Errorf("解析配置文件失败：%s", err)
 		panic(err)
 	}
+	cfgPtr.GetBaseConfig().path = path
 }
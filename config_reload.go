@@ -0,0 +1,165 @@
+package gonebot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// 检查配置文件是否修改的间隔
+const defaultConfigWatchInterval = 2 * time.Second
+
+// 可以原子替换的配置
+type configHolder struct {
+	v atomic.Value
+}
+
+// atomic.Value要求每次存入的类型相同，包一层
+type configBox struct {
+	cfg Config
+}
+
+func (h *configHolder) load() Config {
+	if h == nil {
+		return nil
+	}
+	box, _ := h.v.Load().(configBox)
+	return box.cfg
+}
+
+func (h *configHolder) store(cfg Config) {
+	h.v.Store(configBox{cfg})
+}
+
+// 当前的配置。配置文件重新载入后返回新的配置
+func (engine *Engine) GetConfig() Config {
+	return engine.config.load()
+}
+
+// 同一时间只进行一次重新载入
+var configReloadLock sync.Mutex
+
+// 重新载入配置文件，并替换所有已加载插件的配置结构体，新的配置通过GetPluginConfig获取。
+// 文件无法解析或有插件的配置有误时，保留原有的配置并返回错误。
+//
+// 服务提供者（provider、provider_config）、插件开关（plugin.enable、plugin_switch）的修改需要重启后生效
+func (engine *Engine) ReloadConfig(path string) error {
+	configReloadLock.Lock()
+	defer configReloadLock.Unlock()
+
+	oldCfg := engine.GetConfig()
+	newCfg, err := decodeConfigFile(path, oldCfg)
+	if err != nil {
+		return err
+	}
+
+	// 先检查所有插件的配置，都没有问题后再替换
+	ids := engine.plugins.ids()
+	pluginCfgs := make(map[string]reflect.Value)
+	errs := []error{}
+	for _, id := range ids {
+		v, err := defaultPluginManager.buildPluginConfig(id, newCfg.GetBaseConfig().Plugin.Config[id])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("插件%s的%w", id, err))
+			continue
+		}
+		if v.IsValid() {
+			pluginCfgs[id] = v
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	engine.config.store(newCfg)
+	for id, v := range pluginCfgs {
+		defaultPluginManager.publishPluginConfig(id, v)
+	}
+	log.Info("配置文件已重新载入")
+
+	engine.Hooks.fireConfigHook(configHook_ConfigReloaded, oldCfg, newCfg)
+	for _, id := range ids {
+		hub := engine.plugins.get(id)
+		if l, ok := hub.plugin.(ConfigChangeListener); ok {
+			l.OnConfigChange(hub)
+		}
+	}
+	return nil
+}
+
+// 将配置文件解析为与cfg相同类型的新配置
+func decodeConfigFile(path string, cfg Config) (Config, error) {
+	t := reflect.TypeOf(cfg)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("配置必须为结构体指针才能重新载入，而不是%T", cfg)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开配置文件失败：%w", err)
+	}
+	newCfg := reflect.New(t.Elem()).Interface().(Config)
+	if err = yaml.Unmarshal(data, newCfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败：%w", err)
+	}
+	newCfg.GetBaseConfig().path = path
+	return newCfg, nil
+}
+
+// 每隔interval检查一次配置文件，内容变化时重新载入，载入失败时继续使用原有的配置。
+// 调用返回的stop停止检查，stop会等待正在进行的重新载入完成
+func (engine *Engine) WatchConfigFile(path string, interval time.Duration) (stop func()) {
+	// 记录开始时的状态，只在之后有变化时才重新载入
+	lastInfo, _ := os.Stat(path)
+	lastData, _ := os.ReadFile(path)
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				// 编辑器保存时可能先删除再创建，等下一次检查
+				continue
+			}
+			if lastInfo != nil && info.ModTime().Equal(lastInfo.ModTime()) && info.Size() == lastInfo.Size() {
+				continue
+			}
+			lastInfo = info
+
+			data, err := os.ReadFile(path)
+			if err != nil || bytes.Equal(data, lastData) {
+				continue
+			}
+			lastData = data
+
+			log.Infof("配置文件%s已修改，正在重新载入", path)
+			if err := engine.ReloadConfig(path); err != nil {
+				log.Errorf("重新载入配置文件失败，继续使用原有的配置：%s", err)
+			}
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
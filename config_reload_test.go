@@ -0,0 +1,100 @@
+package gonebot
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type reloadTestConfig struct {
+	Greeting string `default:"hi"`
+	Times    int    `min:"1" default:"1"`
+}
+
+type reloadTestPlugin struct {
+	changed int32
+}
+
+func (p *reloadTestPlugin) Init(hub *PluginHub) {}
+
+func (p *reloadTestPlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: "reload", Author: "t"}
+}
+
+func (p *reloadTestPlugin) OnConfigChange(hub *PluginHub) {
+	atomic.AddInt32(&p.changed, 1)
+}
+
+func Test_ReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("superuser: [1]\n")
+
+	plugin := &reloadTestPlugin{}
+	cfg := &reloadTestConfig{}
+	RegisterPlugin(plugin, cfg)
+	defer func() {
+		delete(defaultPluginManager.plugins, "reload@t")
+		delete(defaultPluginManager.pluginConfigStructs, "reload@t")
+		delete(defaultPluginManager.pluginConfigInitial, "reload@t")
+		delete(defaultPluginManager.pluginConfigCurrent, "reload@t")
+	}()
+	current := func() *reloadTestConfig {
+		return GetPluginConfig(plugin).(*reloadTestConfig)
+	}
+
+	engine := NewEngineWithProvider(LoadConfig(path), &recordingProvider{})
+	defer engine.heartbeat.stop()
+	var hooked int32
+	engine.Hooks.ConfigReloaded(func(oldConfig, newConfig Config) { atomic.AddInt32(&hooked, 1) })
+
+	write("superuser: [2]\nplugin:\n  config:\n    reload@t:\n      times: 3\n")
+	if err := engine.ReloadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if su := engine.GetConfig().GetBaseConfig().Superuser; len(su) != 1 || su[0] != 2 {
+		t.Errorf("superuser未更新：%v", su)
+	}
+	if c := current(); c.Greeting != "hi" || c.Times != 3 {
+		t.Errorf("插件配置未更新：%+v", c)
+	}
+	if cfg.Times != 1 {
+		t.Errorf("注册的配置结构体不应被修改：%+v", cfg)
+	}
+	if hooked != 1 || plugin.changed != 1 {
+		t.Errorf("应通知1次，实际钩子%d次，OnConfigChange%d次", hooked, plugin.changed)
+	}
+
+	// 配置有误时保留原有的配置
+	for _, content := range []string{
+		"superuser: [3]\nplugin:\n  config:\n    reload@t:\n      times: 0\n",
+		"superuser: [3\n",
+	} {
+		write(content)
+		if err := engine.ReloadConfig(path); err == nil {
+			t.Errorf("配置%q应返回错误", content)
+		}
+		if su := engine.GetConfig().GetBaseConfig().Superuser; su[0] != 2 || current().Times != 3 {
+			t.Errorf("配置%q有误，不应替换原有的配置", content)
+		}
+	}
+
+	stop := engine.WatchConfigFile(path, 5*time.Millisecond)
+	write("superuser: [4]\n")
+	for i := 0; i < 100 && engine.GetConfig().GetBaseConfig().Superuser[0] != 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	if engine.GetConfig().GetBaseConfig().Superuser[0] != 4 {
+		t.Error("修改配置文件后应自动重新载入")
+	}
+	if c := current(); c.Times != 1 {
+		t.Errorf("配置中删除的项应恢复为默认值，实际为%d", c.Times)
+	}
+}
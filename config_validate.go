@@ -0,0 +1,378 @@
+package gonebot
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 插件配置结构体可以实现该接口，在载入配置并通过标签检查后调用，用于检查字段之间的关系等
+type ConfigValidator interface {
+	Validate() error
+}
+
+// 配置项的错误
+type ConfigFieldError struct {
+	Field string // 配置项的路径，如items[0].name，为空表示整个配置
+	Err   error
+}
+
+func (e *ConfigFieldError) Error() string {
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *ConfigFieldError) Unwrap() error {
+	return e.Err
+}
+
+// 一份配置中的所有错误
+type ConfigErrors []*ConfigFieldError
+
+func (errs ConfigErrors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, e := range errs {
+		lines = append(lines, "  - "+e.Error())
+	}
+	return fmt.Sprintf("%s，共%d处：\n%s", ErrInvalidConfig, len(errs), strings.Join(lines, "\n"))
+}
+
+func (errs ConfigErrors) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+func (errs *ConfigErrors) add(field string, err error) {
+	*errs = append(*errs, &ConfigFieldError{Field: field, Err: err})
+}
+
+// 配置项的名称，用于错误信息与JSON Schema。依次使用json tag、yaml tag、蛇形命名
+func configFieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "yaml"} {
+		if name := strings.Split(field.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return camelCaseToSnakeCase(field.Name)
+}
+
+// 为零值字段填充default标签中的默认值，递归处理嵌套的结构体。v为结构体
+func applyConfigDefaults(v reflect.Value, path string, errs *ConfigErrors) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		field := t.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		fieldPath := joinConfigPath(path, configFieldName(field))
+
+		if def, ok := field.Tag.Lookup("default"); ok && f.IsZero() {
+			typeConvert(parseDefaultTag(def, field.Type), f, fieldPath, errs)
+		}
+		switch {
+		case f.Kind() == reflect.Struct:
+			applyConfigDefaults(f, fieldPath, errs)
+		case f.Kind() == reflect.Ptr && !f.IsNil() && f.Elem().Kind() == reflect.Struct:
+			applyConfigDefaults(f.Elem(), fieldPath, errs)
+		}
+	}
+}
+
+// 新建的值为结构体（或结构体指针）时填充默认值，用于列表、对象中的元素
+func withConfigDefaults(v reflect.Value, path string, errs *ConfigErrors) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		applyConfigDefaults(v, path, errs)
+	}
+}
+
+// 默认值以字符串形式写在标签中，列表以逗号分隔
+func parseDefaultTag(def string, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		items := []interface{}{}
+		for _, item := range strings.Split(def, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return def
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// 按required、min、max、enum标签检查字段，并调用Validate方法。v为结构体
+func validateConfig(v reflect.Value, path string, errs *ConfigErrors) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldPath := joinConfigPath(path, configFieldName(field))
+
+		if field.Tag.Get("required") == "true" && f.IsZero() {
+			errs.add(fieldPath, fmt.Errorf("必须填写"))
+			continue
+		}
+		validateConfigValue(f, field.Tag, fieldPath, errs)
+	}
+
+	if v.CanAddr() {
+		if validator, ok := v.Addr().Interface().(ConfigValidator); ok {
+			if err := validator.Validate(); err != nil {
+				errs.add(path, err)
+			}
+		}
+	}
+}
+
+func validateConfigValue(f reflect.Value, tag reflect.StructTag, path string, errs *ConfigErrors) {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return
+		}
+		f = f.Elem()
+	}
+
+	// 数字比较大小，字符串、列表、对象比较长度
+	if size, unit, ok := configValueSize(f); ok {
+		if min, ok := tag.Lookup("min"); ok {
+			if n, err := strconv.ParseFloat(min, 64); err != nil {
+				errs.add(path, fmt.Errorf("min标签%q不是数字", min))
+			} else if size < n {
+				errs.add(path, fmt.Errorf("%s不能小于%s，实际为%v", unit, min, size))
+			}
+		}
+		if max, ok := tag.Lookup("max"); ok {
+			if n, err := strconv.ParseFloat(max, 64); err != nil {
+				errs.add(path, fmt.Errorf("max标签%q不是数字", max))
+			} else if size > n {
+				errs.add(path, fmt.Errorf("%s不能大于%s，实际为%v", unit, max, size))
+			}
+		}
+	}
+
+	if enum, ok := tag.Lookup("enum"); ok {
+		options := strings.Split(enum, ",")
+		check := func(v reflect.Value, p string) {
+			s := fmt.Sprint(v.Interface())
+			for _, opt := range options {
+				if s == strings.TrimSpace(opt) {
+					return
+				}
+			}
+			errs.add(p, fmt.Errorf("只能为%s之一，实际为%q", strings.Join(options, "、"), s))
+		}
+		if f.Kind() == reflect.Slice || f.Kind() == reflect.Array {
+			for i := 0; i < f.Len(); i++ {
+				check(f.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			}
+		} else {
+			check(f, path)
+		}
+	}
+
+	// 递归检查嵌套的结构体
+	switch f.Kind() {
+	case reflect.Struct:
+		validateConfig(f, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < f.Len(); i++ {
+			if elem := reflect.Indirect(f.Index(i)); elem.Kind() == reflect.Struct {
+				validateConfig(elem, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case reflect.Map:
+		keys := f.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			// map中的值不可寻址，复制一份再检查
+			elem := reflect.Indirect(f.MapIndex(k))
+			if elem.Kind() == reflect.Struct {
+				cp := reflect.New(elem.Type()).Elem()
+				cp.Set(elem)
+				validateConfig(cp, joinConfigPath(path, fmt.Sprint(k)), errs)
+			}
+		}
+	}
+}
+
+// 数字的值，或字符串、列表、对象的长度
+func configValueSize(f reflect.Value) (float64, string, bool) {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(f.Int()), "值", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(f.Uint()), "值", true
+	case reflect.Float32, reflect.Float64:
+		return f.Float(), "值", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(f.String())), "长度", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(f.Len()), "元素个数", true
+	}
+	return 0, "", false
+}
+
+// 生成配置结构体的JSON Schema（draft-07），可用于检查配置文件。cfgStruct为结构体或其指针
+func ConfigSchema(cfgStruct interface{}) map[string]interface{} {
+	t := reflect.TypeOf(cfgStruct)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schema := typeSchema(t)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	return schema
+}
+
+// 插件配置的JSON Schema，插件没有配置时返回nil
+func PluginConfigSchema(plugin Plugin) map[string]interface{} {
+	cfg := GetPluginConfig(plugin)
+	if cfg == nil {
+		return nil
+	}
+	schema := ConfigSchema(cfg)
+	schema["title"] = getPluginId(plugin)
+	return schema
+}
+
+// 所有已注册插件的配置的JSON Schema，键为插件ID
+func PluginConfigSchemas() map[string]map[string]interface{} {
+	ret := make(map[string]map[string]interface{})
+	for id, plugin := range defaultPluginManager.plugins {
+		if schema := PluginConfigSchema(plugin); schema != nil {
+			ret[id] = schema
+		}
+	}
+	return ret
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return map[string]interface{}{"type": []string{"string", "integer"}}
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := configFieldName(field)
+			properties[name] = fieldSchema(field)
+			if field.Tag.Get("required") == "true" {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// 字段的Schema，包括标签中的默认值与限制
+func fieldSchema(field reflect.StructField) map[string]interface{} {
+	schema := typeSchema(field.Type)
+	t := field.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// 将标签中的字符串转换为字段类型对应的JSON值
+	typedValue := func(s interface{}, typ reflect.Type) (interface{}, bool) {
+		v := reflect.New(typ).Elem()
+		errs := ConfigErrors{}
+		typeConvert(s, v, "", &errs)
+		if len(errs) > 0 {
+			return nil, false
+		}
+		if typ == durationType || reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+			return s, true
+		}
+		return v.Interface(), true
+	}
+
+	if def, ok := field.Tag.Lookup("default"); ok {
+		if v, ok := typedValue(parseDefaultTag(def, field.Type), t); ok {
+			schema["default"] = v
+		}
+	}
+	if enum, ok := field.Tag.Lookup("enum"); ok {
+		elemType := t
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			elemType = t.Elem()
+		}
+		values := []interface{}{}
+		for _, opt := range strings.Split(enum, ",") {
+			if v, ok := typedValue(strings.TrimSpace(opt), elemType); ok {
+				values = append(values, v)
+			}
+		}
+		if elemType == t {
+			schema["enum"] = values
+		} else if items, ok := schema["items"].(map[string]interface{}); ok {
+			items["enum"] = values
+		}
+	}
+
+	limits := map[reflect.Kind][2]string{
+		reflect.String: {"minLength", "maxLength"},
+		reflect.Slice:  {"minItems", "maxItems"},
+		reflect.Array:  {"minItems", "maxItems"},
+		reflect.Map:    {"minProperties", "maxProperties"},
+	}
+	keys, ok := limits[t.Kind()]
+	if !ok {
+		keys = [2]string{"minimum", "maximum"}
+	}
+	for i, tagName := range []string{"min", "max"} {
+		if s, ok := field.Tag.Lookup(tagName); ok {
+			if n, err := strconv.ParseFloat(s, 64); err == nil {
+				schema[keys[i]] = n
+			}
+		}
+	}
+	return schema
+}
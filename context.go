@@ -29,6 +29,7 @@ type Context struct {
 	Engine            *Engine                // Engine实例
 	Handler           *Handler
 	atSenderWhenReply bool
+	handlerTimer      *handlerTimer // 插件处理函数的超时计时
 	mu                sync.RWMutex
 	action
 }
@@ -72,8 +73,11 @@ func (ctx *Context) Replyf(tmpl string, args ...interface{}) (err error) {
 	return ctx.ReplyMsg(msg)
 }
 
-// 使用Message对象回复
+// 使用Message对象回复。配置中开启了long_message.auto时，超长消息会被自动分割
 func (ctx *Context) ReplyMsg(msg Message) (err error) {
+	if ctx.Engine != nil && ctx.Engine.GetConfig().GetBaseConfig().LongMessage.Auto {
+		return ctx.ReplyLong(msg, nil)
+	}
 	return ctx.replyBasic(msg, nil)
 }
 
@@ -338,18 +342,27 @@ func (ctx *Context) GetSlice(key string) (s []interface{}) {
 func (ctx *Context) WaitForNextEvent(timeout int, middlewares ...Middleware) I_Event {
 	ch := make(chan I_Event, 1)
 
-	tempHandler, remove := ctx.Handler.parent.NewRemovableHandler()
+	tempHandler := newHandler()
 	tempHandler.Use(middlewares...).Handle(func(c *Context) {
 		ch <- c.Event
 		close(ch)
 	})
-	defer remove()
+	parent := ctx.Handler.parent
+	parent.addSubHandler(tempHandler, EventName_AllEvent)
+	defer parent.removeSubHandler(tempHandler, EventName_AllEvent)
+
+	// 等待的时间不计入处理函数的耗时
+	ctx.handlerTimer.pause()
+	defer ctx.handlerTimer.resume()
 
 	select {
 	case <-time.After(time.Duration(timeout) * time.Second):
 		return nil
 	case event := <-ch:
 		return event
+	case <-ctx.Handler.pluginUnloadSignal():
+		// 插件被禁用，不再等待
+		return nil
 	}
 }
 
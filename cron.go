@@ -0,0 +1,205 @@
+package gonebot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 定时任务的执行时间
+type jobSchedule interface {
+	// t之后（不含t）的下一次执行时间，没有时返回零值
+	next(t time.Time) time.Time
+}
+
+// cron表达式，按位记录每个字段允许的值
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // 日、星期是否为*，都不是*时满足其一即可
+	loc                           *time.Location
+}
+
+// 每隔固定时间执行
+type everySchedule struct {
+	interval time.Duration
+}
+
+// 只执行一次
+type onceSchedule struct {
+	at time.Time
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDowNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// 解析cron表达式，格式为“分 时 日 月 星期”，支持*、a-b、*/n、a-b/n、逗号分隔的列表，
+// 月和星期可以使用英文缩写，星期的0和7都表示周日。
+// 另外支持@daily、@hourly等，以及“@every 1h30m”。loc为计算执行时间使用的时区
+func parseCron(expr string, loc *time.Location) (jobSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: %q的间隔有误", ErrInvalidCron, expr)
+		}
+		return &everySchedule{interval: d}, nil
+	}
+	if desc, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = desc
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q应有5个字段，实际为%d个", ErrInvalidCron, expr, len(fields))
+	}
+	s := &cronSchedule{loc: loc}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("%w: 分钟%s", ErrInvalidCron, err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("%w: 小时%s", ErrInvalidCron, err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("%w: 日%s", ErrInvalidCron, err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("%w: 月%s", ErrInvalidCron, err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, cronDowNames); err != nil {
+		return nil, fmt.Errorf("%w: 星期%s", ErrInvalidCron, err)
+	}
+	// 7也表示周日
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*" || fields[2] == "?"
+	s.dowAny = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// 解析单个字段，返回允许的值的位集合
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("的步长%q有误", part[i+1:])
+			}
+			rng, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		if rng != "*" && rng != "?" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 如5/15表示从5开始每15
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("的范围%q超出%d-%d", rng, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("的值%q有误", s)
+	}
+	return v, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domOk := s.dom&(1<<t.Day()) != 0
+	dowOk := s.dow&(1<<t.Weekday()) != 0
+	if s.domAny || s.dowAny {
+		return domOk && dowOk
+	}
+	return domOk || dowOk
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := s.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	// 从下一个整分钟开始
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+
+	// 逐级跳过不满足的月、日、时、分，最多向后查找5年
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if s.month&(1<<t.Month()) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// 夏令时结束，时钟回拨
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *everySchedule) next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (s *onceSchedule) next(t time.Time) time.Time {
+	if s.at.After(t) {
+		return s.at
+	}
+	return time.Time{}
+}
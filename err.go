@@ -5,4 +5,23 @@ import "errors"
 var (
 	ErrInvalidMessageType = errors.New("不正确的message type")
 	ErrMissingAnonymous   = errors.New("缺少anonymous对象")
+	ErrInvalidCQCode      = errors.New("CQ码格式错误")
+
+	ErrEventTypeConflict = errors.New("事件类型冲突")
+	ErrInvalidEventType  = errors.New("事件类型不合法")
+
+	ErrMediaTooLarge          = errors.New("媒体文件过大")
+	ErrUnsupportedMediaFormat = errors.New("不支持的媒体格式")
+
+	ErrPluginDependencyMissing  = errors.New("缺少依赖的插件")
+	ErrPluginDependencyDisabled = errors.New("依赖的插件未启用")
+	ErrPluginDependencyVersion  = errors.New("依赖的插件版本不符")
+	ErrPluginDependencyCycle    = errors.New("插件循环依赖")
+	ErrPluginNotFound           = errors.New("插件不存在")
+
+	ErrInvalidConfig = errors.New("配置有误")
+
+	ErrUnknownStorageType = errors.New("未知的存储类型")
+
+	ErrInvalidCron = errors.New("cron表达式有误")
 )
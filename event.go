@@ -3,7 +3,9 @@ package gonebot
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -14,6 +16,8 @@ const (
 	PostType_MessageEvent = "message"
 	PostType_NoticeEvent  = "notice"
 	PostType_RequestEvent = "request"
+
+	PostType_MessageSentEvent = "message_sent" // 机器人自己发送的消息，go-cqhttp扩展
 )
 
 type EventName string
@@ -42,9 +46,25 @@ const (
 	EventName_Meta            EventName = "meta_event"
 	EventName_MetaLifecycle   EventName = "meta_event.lifecycle"
 	EventName_MetaHeartbeat   EventName = "meta_event.heartbeat"
+
+	EventName_GuildMessage      EventName = "message.guild"
+	EventName_NotifyTitle       EventName = "notice.notify.title"
+	EventName_GroupMsgEmojiLike EventName = "notice.group_msg_emoji_like"
+
+	EventName_GuildMessageReactionsUpdated EventName = "notice.message_reactions_updated"
+	EventName_GuildChannelUpdated          EventName = "notice.channel_updated"
+	EventName_GuildChannelCreated          EventName = "notice.channel_created"
+	EventName_GuildChannelDestroyed        EventName = "notice.channel_destroyed"
+
+	EventName_MessageSent        EventName = "message_sent"
+	EventName_PrivateMessageSent EventName = "message_sent.private"
+	EventName_GroupMessageSent   EventName = "message_sent.group"
 )
 
-var eventTypeMap map[string]I_Event
+var (
+	eventTypeMap  map[string]I_Event
+	eventTypeLock sync.RWMutex
+)
 
 func init() {
 	eventTypeMap = map[string]I_Event{
@@ -69,6 +89,18 @@ func init() {
 		"request.group":            &GroupRequestEvent{},
 		"meta_event.lifecycle":     &LifeCycleMetaEvent{},
 		"meta_event.heartbeat":     &HeartbeatMetaEvent{},
+
+		"notice.notify.title":         &TitleNoticeEvent{},
+		"notice.group_msg_emoji_like": &GroupMsgEmojiLikeNoticeEvent{},
+
+		"message_sent.private": &PrivateMessageSentEvent{},
+		"message_sent.group":   &GroupMessageSentEvent{},
+
+		"message.guild":                    &GuildMessageEvent{},
+		"notice.message_reactions_updated": &GuildMessageReactionsUpdatedNoticeEvent{},
+		"notice.channel_updated":           &GuildChannelUpdatedNoticeEvent{},
+		"notice.channel_created":           &GuildChannelCreatedNoticeEvent{},
+		"notice.channel_destroyed":         &GuildChannelDestroyedNoticeEvent{},
 	}
 }
 
@@ -94,6 +126,59 @@ type Event struct {
 
 	EventName EventName `json:"-"` // 事件的名称，形如：notice.group.set
 	ToMe      bool      `json:"-"` // 是否与我（bot）有关（即私聊我、或群聊At我、我被踢了、等等）
+
+	raw      gjson.Result
+	filtered bool
+}
+
+// 协议端上报的原始JSON
+func (e *Event) Raw() gjson.Result {
+	return e.raw
+}
+
+func (e *Event) setRaw(raw gjson.Result) {
+	e.raw = raw
+}
+
+// 是否被过滤
+func (e *Event) IsFiltered() bool {
+	return e.filtered
+}
+
+func (e *Event) setFiltered(filtered bool) {
+	e.filtered = filtered
+}
+
+// 所有嵌入了Event的事件都实现了该接口，用于保存原始JSON
+type rawEventSetter interface {
+	setRaw(raw gjson.Result)
+}
+
+// 可选的接口，所有嵌入了Event的事件都实现了
+type RawEvent interface {
+	Raw() gjson.Result
+}
+
+// 可选的接口，所有嵌入了Event的事件都实现了
+type FilterableEvent interface {
+	IsFiltered() bool
+}
+
+// 协议端上报的原始JSON，可用于读取未建模的字段。非上报的事件（如手动构造的）、未嵌入Event的事件返回空值
+func EventRaw(ev I_Event) gjson.Result {
+	if r, ok := ev.(RawEvent); ok {
+		return r.Raw()
+	}
+	return gjson.Result{}
+}
+
+// 事件是否被Engine的过滤器过滤，被过滤的事件仍会触发EventRecieved钩子，但不会交给Handler处理。
+// 未嵌入Event的事件总是返回false
+func IsEventFiltered(ev I_Event) bool {
+	if f, ok := ev.(FilterableEvent); ok {
+		return f.IsFiltered()
+	}
+	return false
 }
 
 // 获取事件的上报类型，有message, notice, request, meta_event
@@ -145,6 +230,10 @@ func ConvertJsonObjectToEvent(obj gjson.Result) I_Event {
 	// 所以下面只用了第一级和第二级类型来构造事件对象
 	postType := obj.Get("post_type").String()
 	nextType := obj.Get(postType + "_type").String()
+	if postType == PostType_MessageSentEvent {
+		// 自身消息的第二级类型同样在message_type中
+		nextType = obj.Get("message_type").String()
+	}
 	typeName := fmt.Sprintf("%s.%s", postType, nextType) // 前两段类型
 
 	subType := ""
@@ -154,12 +243,8 @@ func ConvertJsonObjectToEvent(obj gjson.Result) I_Event {
 		fullTypeName = fmt.Sprintf("%s.%s", typeName, subType)
 	}
 
-	var ev I_Event
-	if event, ok := eventTypeMap[fullTypeName]; ok {
-		ev = event
-	} else if event, ok := eventTypeMap[typeName]; ok {
-		ev = event
-	} else {
+	ev := lookupEventType(fullTypeName, typeName)
+	if ev == nil {
 		logrus.Warnf("暂未支持的事件类型 %s ，将转换为基本事件。", fullTypeName)
 		ev = &Event{}
 	}
@@ -167,12 +252,17 @@ func ConvertJsonObjectToEvent(obj gjson.Result) I_Event {
 	// 由于使用的是指针，需要拷贝一份Event对象再来填充数据
 	ev = createUnderlyingStruct(ev).(I_Event)
 
-	// 借助json库将JSON对象中的字段赋值给Event对象，懒得自个写反射了
+	// 借助json库将JSON对象中的字段赋值给Event对象，懒得自个写反射了。
+	// 字符串格式（CQ码）的message也会在这里被解析为消息段，见Message.UnmarshalJSON
 	err := json.Unmarshal([]byte(obj.Raw), ev)
 	if err != nil {
 		panic(err)
 	}
 
+	if setter, ok := ev.(rawEventSetter); ok {
+		setter.setRaw(obj)
+	}
+
 	// 设置事件的名称
 	setEventField(ev, "EventName", EventName(fullTypeName))
 	if isEventRelativeToBot(ev) {
@@ -182,6 +272,96 @@ func ConvertJsonObjectToEvent(obj gjson.Result) I_Event {
 	return ev
 }
 
+// 将事件序列化为JSON。协议端上报的事件直接使用原始JSON，手动构造的事件按字段序列化。
+// 结果可由UnmarshalEvent还原
+func MarshalEvent(ev I_Event) ([]byte, error) {
+	if raw := EventRaw(ev); raw.Exists() {
+		return []byte(raw.Raw), nil
+	}
+	return json.Marshal(ev)
+}
+
+// 从JSON还原事件，同ConvertJsonObjectToEvent
+func UnmarshalEvent(data []byte) (ev I_Event, err error) {
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("事件不是合法的JSON")
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("转换事件失败：%v", e)
+		}
+	}()
+	return ConvertJsonObjectToEvent(gjson.ParseBytes(data)), nil
+}
+
+// 按三段类型、两段类型的顺序查找已注册的事件类型，找不到时返回nil
+func lookupEventType(names ...string) I_Event {
+	eventTypeLock.RLock()
+	defer eventTypeLock.RUnlock()
+	for _, name := range names {
+		if ev, ok := eventTypeMap[name]; ok {
+			return ev
+		}
+	}
+	return nil
+}
+
+// 注册自定义的事件类型，用于支持协议端特有的事件。
+//
+// name为两段或三段的事件名称，如"notice.group_card"、"notice.notify.title"。
+// 转换事件时优先匹配三段名称，其次为两段名称。
+// prototype为事件结构体的指针，结构体必须嵌入Event（或其他已有的事件结构体），字段通过json tag从上报数据中读取。
+//
+// 该名称已被注册为其他类型时返回ErrEventTypeConflict，如需替换内置类型，请使用OverrideEventType。
+// 重复注册相同的类型不会报错。
+func RegisterEventType(name EventName, prototype I_Event) error {
+	return registerEventType(name, prototype, false)
+}
+
+// 注册事件类型，该名称已存在时替换原有类型
+func OverrideEventType(name EventName, prototype I_Event) error {
+	return registerEventType(name, prototype, true)
+}
+
+func registerEventType(name EventName, prototype I_Event, override bool) error {
+	if err := validateEventPrototype(name, prototype); err != nil {
+		return err
+	}
+
+	eventTypeLock.Lock()
+	defer eventTypeLock.Unlock()
+
+	if old, ok := eventTypeMap[string(name)]; ok && !override {
+		if reflect.TypeOf(old) == reflect.TypeOf(prototype) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s已被注册为%T", ErrEventTypeConflict, name, old)
+	}
+	eventTypeMap[string(name)] = prototype
+	return nil
+}
+
+func validateEventPrototype(name EventName, prototype I_Event) error {
+	parts := strings.Split(string(name), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("%w: 事件名称%s应为两段或三段", ErrInvalidEventType, name)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf("%w: 事件名称%s中存在空的类型", ErrInvalidEventType, name)
+		}
+	}
+
+	v := reflect.ValueOf(prototype)
+	if prototype == nil || v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: 事件类型应为结构体指针，实际为%T", ErrInvalidEventType, prototype)
+	}
+	if _, ok := prototype.(rawEventSetter); !ok {
+		return fmt.Errorf("%w: %T未嵌入Event", ErrInvalidEventType, prototype)
+	}
+	return nil
+}
+
 type MessageEvent struct {
 	Event
 	MessageType string  `json:"message_type"` // 消息类型，group, private
@@ -239,14 +419,18 @@ type PrivateMessageEvent struct {
 	Sender *MessageEventSender `json:"sender"` // 发送人信息
 }
 
-func (e *PrivateMessageEvent) GetEventDescription() string {
-	msg := e.Message.String()
+// 用于日志输出的消息内容，过长时省略中间部分
+func abbreviateMessage(m Message) string {
+	msg := m.String()
 	msgRune := []rune(msg)
 	if len(msgRune) > 100 {
 		msg = fmt.Sprintf("%s...(省略%d个字符)...%s", string(msgRune[:50]), len(msgRune)-100, string(msgRune[len(msgRune)-50:]))
 	}
-	msg = strings.Replace(msg, "\n", "\\n", -1)
-	return fmt.Sprintf("[私聊消息](#%d 来自%d): %v", e.MessageId, e.UserId, msg)
+	return strings.Replace(msg, "\n", "\\n", -1)
+}
+
+func (e *PrivateMessageEvent) GetEventDescription() string {
+	return fmt.Sprintf("[私聊消息](#%d 来自%d): %v", e.MessageId, e.UserId, abbreviateMessage(e.Message))
 }
 
 type Anonymous struct {
@@ -263,19 +447,82 @@ type GroupMessageEvent struct {
 }
 
 func (e *GroupMessageEvent) GetEventDescription() string {
-	msg := e.Message.String()
-	msgRune := []rune(msg)
-	if len(msgRune) > 100 {
-		msg = fmt.Sprintf("%s...(省略%d个字符)...%s", string(msgRune[:50]), len(msgRune)-100, string(msgRune[len(msgRune)-50:]))
-	}
-	msg = strings.Replace(msg, "\n", "\\n", -1)
-	return fmt.Sprintf("[群聊消息](#%d 来自%d@群%d): %v", e.MessageId, e.UserId, e.GroupId, msg)
+	return fmt.Sprintf("[群聊消息](#%d 来自%d@群%d): %v", e.MessageId, e.UserId, e.GroupId, abbreviateMessage(e.Message))
 }
 
 func (e *GroupMessageEvent) GetSessionId() string {
 	return fmt.Sprintf("%d@%d", e.UserId, e.GroupId)
 }
 
+// 机器人自己发送的私聊消息（go-cqhttp扩展，需在协议端开启自身消息上报）
+type PrivateMessageSentEvent struct {
+	PrivateMessageEvent
+	TargetId int64 `json:"target_id"` // 接收者的QQ号
+}
+
+func (e *PrivateMessageSentEvent) GetEventDescription() string {
+	return fmt.Sprintf("[自身私聊消息](#%d 发给%d): %v", e.MessageId, e.TargetId, abbreviateMessage(e.Message))
+}
+
+// 会话ID与对方发来的私聊消息一致，即对方的QQ号
+func (e *PrivateMessageSentEvent) GetSessionId() string {
+	return fmt.Sprintf("%d", e.TargetId)
+}
+
+// 机器人自己发送的群聊消息（go-cqhttp扩展，需在协议端开启自身消息上报）
+type GroupMessageSentEvent struct {
+	GroupMessageEvent
+}
+
+func (e *GroupMessageSentEvent) GetEventDescription() string {
+	return fmt.Sprintf("[自身群聊消息](#%d 发到群%d): %v", e.MessageId, e.GroupId, abbreviateMessage(e.Message))
+}
+
+type GuildMessageEventSender struct {
+	UserId   int64  `json:"user_id"`  // 发送者的频道用户ID
+	TinyId   string `json:"tiny_id"`  // 同user_id
+	Nickname string `json:"nickname"` // 发送者昵称
+}
+
+// 频道消息（go-cqhttp扩展）。频道相关的ID均为字符串
+type GuildMessageEvent struct {
+	Event
+	MessageType string                   `json:"message_type"` // 消息类型，guild
+	SubType     string                   `json:"sub_type"`     // 消息子类型，channel
+	GuildId     string                   `json:"guild_id"`     // 频道ID
+	ChannelId   string                   `json:"channel_id"`   // 子频道ID
+	UserId      string                   `json:"user_id"`      // 发送者的频道用户ID
+	MessageId   string                   `json:"message_id"`   // 消息ID
+	Message     Message                  `json:"message"`      // 消息内容
+	SelfTinyId  string                   `json:"self_tiny_id"` // 机器人在频道中的ID
+	Sender      *GuildMessageEventSender `json:"sender"`       // 发送人信息
+}
+
+func (e *GuildMessageEvent) GetSecondType() string {
+	return e.MessageType
+}
+
+func (e *GuildMessageEvent) GetSubType() string {
+	return e.SubType
+}
+
+// 会话ID形如“用户ID@频道ID:子频道ID”
+func (e *GuildMessageEvent) GetSessionId() string {
+	return fmt.Sprintf("%s@%s:%s", e.UserId, e.GuildId, e.ChannelId)
+}
+
+func (e *GuildMessageEvent) GetMessage() *Message {
+	return &e.Message
+}
+
+func (e *GuildMessageEvent) ExtractPlainText() string {
+	return e.Message.ExtractPlainText()
+}
+
+func (e *GuildMessageEvent) GetEventDescription() string {
+	return fmt.Sprintf("[频道消息](#%s 来自%s@频道%s:%s): %v", e.MessageId, e.UserId, e.GuildId, e.ChannelId, abbreviateMessage(e.Message))
+}
+
 type LifeCycleMetaEvent struct {
 	Event
 	MetaEventType string `json:"meta_event_type"` // 元事件类型，lifecycle
@@ -483,6 +730,41 @@ func (e *HonorNoticeEvent) GetSubType() string {
 	return e.SubType
 }
 
+// 群成员头衔变更（go-cqhttp扩展）
+type TitleNoticeEvent struct {
+	NoticeEvent
+	SubType string `json:"sub_type"` // 提示类型，title
+	GroupId int64  `json:"group_id"` // 群号
+	UserId  int64  `json:"user_id"`  // 获得头衔的成员QQ号
+	Title   string `json:"title"`    // 新头衔
+}
+
+func (e *TitleNoticeEvent) GetSessionId() string {
+	return fmt.Sprintf("%d@%d", e.UserId, e.GroupId)
+}
+
+func (e *TitleNoticeEvent) GetSubType() string {
+	return e.SubType
+}
+
+// 群消息表情回应（NapCat、Lagrange等扩展）
+type GroupMsgEmojiLikeNoticeEvent struct {
+	NoticeEvent
+	GroupId   int64       `json:"group_id"`   // 群号
+	UserId    int64       `json:"user_id"`    // 回应者的QQ号
+	MessageId int32       `json:"message_id"` // 被回应的消息ID
+	Likes     []EmojiLike `json:"likes"`      // 回应的表情
+}
+
+type EmojiLike struct {
+	EmojiId string `json:"emoji_id"` // 表情ID
+	Count   int32  `json:"count"`    // 数量
+}
+
+func (e *GroupMsgEmojiLikeNoticeEvent) GetSessionId() string {
+	return fmt.Sprintf("%d@%d", e.UserId, e.GroupId)
+}
+
 // 群成员名片更新
 type GroupCardNoticeEvent struct {
 	NoticeEvent
@@ -523,6 +805,65 @@ type EssenceNoticeEvent struct {
 	MessageId  int32  `json:"message_id"`
 }
 
+// 子频道信息
+type GuildChannelInfo struct {
+	OwnerGuildId  string `json:"owner_guild_id"`  // 所属频道ID
+	ChannelId     string `json:"channel_id"`      // 子频道ID
+	ChannelType   int32  `json:"channel_type"`    // 子频道类型
+	ChannelName   string `json:"channel_name"`    // 子频道名称
+	CreateTime    int64  `json:"create_time"`     // 创建时间
+	CreatorTinyId string `json:"creator_tiny_id"` // 创建者ID
+}
+
+// 频道通知的公共字段（go-cqhttp扩展）
+type GuildNoticeEvent struct {
+	NoticeEvent
+	GuildId   string `json:"guild_id"`   // 频道ID
+	ChannelId string `json:"channel_id"` // 子频道ID
+	UserId    string `json:"user_id"`    // 操作者的频道用户ID
+}
+
+func (e *GuildNoticeEvent) GetSessionId() string {
+	return fmt.Sprintf("%s@%s:%s", e.UserId, e.GuildId, e.ChannelId)
+}
+
+// 频道消息表情贴更新
+type GuildMessageReactionsUpdatedNoticeEvent struct {
+	GuildNoticeEvent
+	MessageId        string          `json:"message_id"`        // 消息ID
+	CurrentReactions []GuildReaction `json:"current_reactions"` // 当前的表情贴
+}
+
+type GuildReaction struct {
+	EmojiId    string `json:"emoji_id"`    // 表情ID
+	EmojiIndex int32  `json:"emoji_index"` // 表情对应数值ID
+	EmojiType  int32  `json:"emoji_type"`  // 表情类型
+	Count      int32  `json:"count"`       // 数量
+	Clicked    bool   `json:"clicked"`     // 机器人是否点击过
+}
+
+// 子频道信息更新
+type GuildChannelUpdatedNoticeEvent struct {
+	GuildNoticeEvent
+	OperatorId string           `json:"operator_id"` // 操作者ID
+	OldInfo    GuildChannelInfo `json:"old_info"`    // 更新前的信息
+	NewInfo    GuildChannelInfo `json:"new_info"`    // 更新后的信息
+}
+
+// 子频道创建
+type GuildChannelCreatedNoticeEvent struct {
+	GuildNoticeEvent
+	OperatorId  string           `json:"operator_id"`  // 操作者ID
+	ChannelInfo GuildChannelInfo `json:"channel_info"` // 子频道信息
+}
+
+// 子频道删除
+type GuildChannelDestroyedNoticeEvent struct {
+	GuildNoticeEvent
+	OperatorId  string           `json:"operator_id"`  // 操作者ID
+	ChannelInfo GuildChannelInfo `json:"channel_info"` // 子频道信息
+}
+
 // ==========================
 // 请求事件
 // ==========================
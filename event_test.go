@@ -0,0 +1,176 @@
+package gonebot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func Test_ConvertExtendedEvent(t *testing.T) {
+	tests := []struct {
+		json      string
+		name      EventName
+		sessionId string
+		toMe      bool
+	}{
+		{
+			`{"post_type": "message_sent", "message_type": "private", "sub_type": "friend", "self_id": 10000,
+			  "user_id": 10000, "target_id": 114514, "message_id": 1, "message": "hi", "sender": {"user_id": 10000}}`,
+			"message_sent.private.friend", "114514", false,
+		},
+		{
+			`{"post_type": "message_sent", "message_type": "group", "sub_type": "normal", "self_id": 10000,
+			  "user_id": 10000, "group_id": 1919810, "message_id": 1, "message": "hi", "sender": {"user_id": 10000}}`,
+			"message_sent.group.normal", "10000@1919810", false,
+		},
+		{
+			`{"post_type": "message", "message_type": "guild", "sub_type": "channel", "self_id": 10000, "self_tiny_id": "555",
+			  "guild_id": "g1", "channel_id": "c1", "user_id": "777", "message_id": "m1",
+			  "message": "[CQ:at,qq=555] hi", "sender": {"user_id": 777, "tiny_id": "777", "nickname": "n"}}`,
+			"message.guild.channel", "777@g1:c1", true,
+		},
+		{
+			`{"post_type": "notice", "notice_type": "group_msg_emoji_like", "self_id": 10000,
+			  "group_id": 1919810, "user_id": 114514, "message_id": 3, "likes": [{"emoji_id": "76", "count": 1}]}`,
+			"notice.group_msg_emoji_like", "114514@1919810", false,
+		},
+		{
+			`{"post_type": "notice", "notice_type": "notify", "sub_type": "title", "self_id": 10000,
+			  "group_id": 1919810, "user_id": 10000, "title": "头衔"}`,
+			"notice.notify.title", "10000@1919810", true,
+		},
+		{
+			`{"post_type": "notice", "notice_type": "channel_created", "self_id": 10000,
+			  "guild_id": "g1", "channel_id": "c2", "user_id": "777", "operator_id": "777",
+			  "channel_info": {"channel_id": "c2", "channel_name": "新频道"}}`,
+			"notice.channel_created", "777@g1:c2", false,
+		},
+	}
+	for _, tt := range tests {
+		ev := ConvertJsonObjectToEvent(gjson.Parse(tt.json))
+		if _, ok := ev.(*Event); ok {
+			t.Errorf("%s 不应转换为基本事件", tt.name)
+			continue
+		}
+		if ev.GetEventName() != tt.name {
+			t.Errorf("事件名称为%s，应为%s", ev.GetEventName(), tt.name)
+		}
+		if ev.GetSessionId() != tt.sessionId {
+			t.Errorf("%s 的会话ID为%s，应为%s", tt.name, ev.GetSessionId(), tt.sessionId)
+		}
+		if ev.IsToMe() != tt.toMe {
+			t.Errorf("%s 的ToMe为%v，应为%v", tt.name, ev.IsToMe(), tt.toMe)
+		}
+	}
+
+	ev := ConvertJsonObjectToEvent(gjson.Parse(tests[0].json))
+	if ev.IsMessageEvent() {
+		t.Error("自身消息不应视为消息事件，以免插件回复自己")
+	}
+}
+
+type customEmojiEvent struct {
+	NoticeEvent
+	GroupId int64  `json:"group_id"`
+	Emoji   string `json:"emoji"`
+}
+
+func Test_RegisterEventType(t *testing.T) {
+	defer func() {
+		eventTypeLock.Lock()
+		delete(eventTypeMap, "notice.custom_emoji")
+		eventTypeMap["notice.group_card"] = &GroupCardNoticeEvent{}
+		eventTypeLock.Unlock()
+	}()
+
+	if err := RegisterEventType("notice.custom_emoji", &customEmojiEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterEventType("notice.custom_emoji", &customEmojiEvent{}); err != nil {
+		t.Errorf("重复注册相同类型不应报错：%v", err)
+	}
+	if err := RegisterEventType("notice.group_card", &customEmojiEvent{}); !errors.Is(err, ErrEventTypeConflict) {
+		t.Errorf("与内置类型冲突时应返回ErrEventTypeConflict，err=%v", err)
+	}
+	if err := OverrideEventType("notice.group_card", &customEmojiEvent{}); err != nil {
+		t.Errorf("覆盖内置类型不应报错：%v", err)
+	}
+	for _, name := range []EventName{"notice", "a..b", "a.b.c.d"} {
+		if err := RegisterEventType(name, &customEmojiEvent{}); !errors.Is(err, ErrInvalidEventType) {
+			t.Errorf("%s 应为不合法的名称，err=%v", name, err)
+		}
+	}
+	if err := RegisterEventType("notice.x", nil); !errors.Is(err, ErrInvalidEventType) {
+		t.Errorf("nil应为不合法的类型，err=%v", err)
+	}
+
+	ev := ConvertJsonObjectToEvent(gjson.Parse(`{"post_type": "notice", "notice_type": "custom_emoji", "self_id": 1,
+		"group_id": 2, "emoji": "😀", "vendor_field": {"a": 1}}`))
+	cev, ok := ev.(*customEmojiEvent)
+	if !ok {
+		t.Fatalf("事件类型错误：%T", ev)
+	}
+	if cev.Emoji != "😀" || cev.GroupId != 2 {
+		t.Errorf("字段读取错误：%+v", cev)
+	}
+	if EventRaw(ev).Get("vendor_field.a").Int() != 1 {
+		t.Error("应能从原始JSON中读取未建模的字段")
+	}
+}
+
+func Test_MarshalEvent(t *testing.T) {
+	raw := `{"post_type": "notice", "notice_type": "notify", "sub_type": "title", "self_id": 1, "group_id": 2, "user_id": 3, "title": "t", "extra": 1}`
+	ev := ConvertJsonObjectToEvent(gjson.Parse(raw))
+	b, err := MarshalEvent(ev)
+	if err != nil || string(b) != raw {
+		t.Errorf("上报的事件应使用原始JSON，结果为%s, %v", b, err)
+	}
+
+	manual := &GroupMessageEvent{}
+	manual.PostType = PostType_MessageEvent
+	manual.MessageType = "group"
+	manual.GroupId = 2
+	manual.Message = MsgPrint("hi")
+	b, _ = MarshalEvent(manual)
+	back, err := UnmarshalEvent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gev, ok := back.(*GroupMessageEvent); !ok || gev.GroupId != 2 || !gev.Message.Equal(manual.Message) {
+		t.Errorf("还原的事件与原事件不一致：%#v", back)
+	}
+	if _, err = UnmarshalEvent([]byte("{")); err == nil {
+		t.Error("不合法的JSON应返回错误")
+	}
+}
+
+func Test_HandleEvent(t *testing.T) {
+	root := &Handler{subHandlers: make(map[EventName][]*Handler)}
+	got := []string{}
+	HandleEvent(root, func(ctx *Context, ev *GroupIncreaseNoticeEvent) {
+		got = append(got, "increase")
+		ctx.Next()
+	})
+	HandleEvent(root, func(ctx *Context, ev *PokeNoticeEvent) {
+		got = append(got, "poke")
+		ctx.Next()
+	})
+	HandleEvent(root, func(ctx *Context, ev I_Event) {
+		got = append(got, "all")
+	})
+
+	inc := ConvertJsonObjectToEvent(gjson.Parse(`{"post_type": "notice", "notice_type": "group_increase", "sub_type": "approve", "group_id": 1, "user_id": 2}`))
+	root.handleEvent(newContext(inc, &Engine{}))
+	if strings.Join(got, ",") != "increase,all" {
+		t.Errorf("处理顺序错误：%v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("未注册的事件类型应panic")
+		}
+	}()
+	HandleEvent(root, func(ctx *Context, ev *customEmojiEvent) {})
+}
@@ -0,0 +1,129 @@
+package gonebot
+
+import (
+	"sync"
+)
+
+// 事件过滤器，在事件交给Handler处理之前执行。返回false时丢弃该事件
+type EventFilter func(ev I_Event) bool
+
+// 事件过滤的配置
+type EventFilterConfig struct {
+	AllowUsers  []int64 `yaml:"allow_users"`  // 只处理这些用户的事件，不填则不限制
+	DenyUsers   []int64 `yaml:"deny_users"`   // 不处理这些用户的事件
+	AllowGroups []int64 `yaml:"allow_groups"` // 只处理这些群的事件，不填则不限制。不影响私聊等不属于群的事件
+	DenyGroups  []int64 `yaml:"deny_groups"`  // 不处理这些群的事件
+	IgnoreSelf  bool    `yaml:"ignore_self"`  // 不处理bot自己发出的消息
+	IgnoreBots  []int64 `yaml:"ignore_bots"`  // 其他机器人的QQ号，不处理它们发出的消息，避免互相触发
+}
+
+// 判断事件是否应被处理
+func (cfg *EventFilterConfig) allows(ev I_Event) bool {
+	userId, hasUser := getInt64EventField(ev, "UserId")
+	groupId, hasGroup := getInt64EventField(ev, "GroupId")
+
+	if hasUser {
+		if len(cfg.AllowUsers) > 0 && !containsInt64(cfg.AllowUsers, userId) {
+			return false
+		}
+		if containsInt64(cfg.DenyUsers, userId) {
+			return false
+		}
+	}
+	if hasGroup {
+		if len(cfg.AllowGroups) > 0 && !containsInt64(cfg.AllowGroups, groupId) {
+			return false
+		}
+		if containsInt64(cfg.DenyGroups, groupId) {
+			return false
+		}
+	}
+
+	isMessage := ev.GetPostType() == PostType_MessageEvent
+	if cfg.IgnoreSelf {
+		if ev.GetPostType() == PostType_MessageSentEvent {
+			return false
+		}
+		selfId, _ := getInt64EventField(ev, "SelfId")
+		if isMessage && hasUser && userId == selfId {
+			return false
+		}
+	}
+	if isMessage && hasUser && containsInt64(cfg.IgnoreBots, userId) {
+		return false
+	}
+	return true
+}
+
+func getInt64EventField(ev I_Event, field string) (int64, bool) {
+	v, ok := getEventField(ev, field)
+	if !ok {
+		return 0, false
+	}
+	i, ok := v.(int64)
+	return i, ok
+}
+
+func containsInt64(list []int64, v int64) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// 设置事件是否被过滤
+type filteredEventSetter interface {
+	setFiltered(filtered bool)
+}
+
+type filterList struct {
+	filters []*EventFilter
+	mu      sync.RWMutex
+}
+
+func (l *filterList) add(f EventFilter) (remove func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pf := &f
+	l.filters = append(l.filters, pf)
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, item := range l.filters {
+			if item == pf {
+				l.filters = append(l.filters[:i], l.filters[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// 依次执行过滤器，任一返回false即丢弃
+func (l *filterList) allows(ev I_Event) bool {
+	l.mu.RLock()
+	filters := append([]*EventFilter{}, l.filters...)
+	l.mu.RUnlock()
+	for _, f := range filters {
+		if !(*f)(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// 添加全局的事件过滤器，返回false的事件不会交给任何Handler处理，但仍会触发EventRecieved钩子（IsEventFiltered为true）。
+// 配置文件中的event_filter总是最先执行。返回的函数用于移除该过滤器
+func (engine *Engine) AddFilter(f EventFilter) (remove func()) {
+	return engine.filters.add(f)
+}
+
+// 判断事件是否被过滤，并在事件上做标记
+func (engine *Engine) filterEvent(ev I_Event) bool {
+	filtered := !engine.filters.allows(ev)
+	if setter, ok := ev.(filteredEventSetter); ok {
+		setter.setFiltered(filtered)
+	}
+	return filtered
+}
@@ -0,0 +1,45 @@
+package gonebot
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func Test_EventFilter(t *testing.T) {
+	cfg := &BaseConfig{EventFilter: EventFilterConfig{
+		DenyUsers:   []int64{10},
+		AllowGroups: []int64{100, 200},
+		DenyGroups:  []int64{200},
+		IgnoreSelf:  true,
+		IgnoreBots:  []int64{20},
+	}}
+	engine := NewEngineWithProvider(cfg, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	engine.AddFilter(func(ev I_Event) bool {
+		return ev.ExtractPlainText() != "屏蔽词"
+	})
+
+	tests := []struct {
+		json     string
+		filtered bool
+	}{
+		{`{"post_type": "message", "message_type": "private", "self_id": 1, "user_id": 2, "message": "hi"}`, false},
+		{`{"post_type": "message", "message_type": "private", "self_id": 1, "user_id": 10, "message": "hi"}`, true},
+		{`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 2, "group_id": 100, "message": "hi"}`, false},
+		{`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 2, "group_id": 200, "message": "hi"}`, true},
+		{`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 2, "group_id": 300, "message": "hi"}`, true},
+		{`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 1, "group_id": 100, "message": "hi"}`, true},
+		{`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 20, "group_id": 100, "message": "hi"}`, true},
+		{`{"post_type": "message_sent", "message_type": "private", "self_id": 1, "user_id": 1, "target_id": 2, "message": "hi"}`, true},
+		{`{"post_type": "notice", "notice_type": "group_increase", "self_id": 1, "user_id": 1, "group_id": 100}`, false},
+		{`{"post_type": "message", "message_type": "private", "self_id": 1, "user_id": 2, "message": "屏蔽词"}`, true},
+		{`{"post_type": "meta_event", "meta_event_type": "heartbeat", "self_id": 1, "interval": 5000}`, false},
+	}
+	for i, tt := range tests {
+		ev := ConvertJsonObjectToEvent(gjson.Parse(tt.json))
+		if got := engine.filterEvent(ev); got != tt.filtered || IsEventFiltered(ev) != tt.filtered {
+			t.Errorf("#%d 过滤结果应为%v，实际为%v", i, tt.filtered, got)
+		}
+	}
+}
@@ -3,19 +3,17 @@ module github.com/liwh011/gonebot
 go 1.20
 
 require (
+	github.com/alexflint/go-arg v1.4.3
 	github.com/gorilla/websocket v1.4.2
+	github.com/mitchellh/mapstructure v1.5.0
 	github.com/sirupsen/logrus v1.8.1
+	go.etcd.io/bbolt v1.3.7
 )
 
-require (
-	github.com/alexflint/go-arg v1.4.3 // indirect
-	github.com/alexflint/go-scalar v1.1.0 // indirect
-	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	go.etcd.io/bbolt v1.3.7 // indirect
-)
+require github.com/alexflint/go-scalar v1.1.0 // indirect
 
 require (
-	github.com/tidwall/gjson v1.13.0 // indirect
+	github.com/tidwall/gjson v1.13.0
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	golang.org/x/sys v0.4.0 // indirect
@@ -13,10 +13,28 @@ import (
 
 type Engine struct {
 	Handler
+	// 创建Engine时传入的配置。
+	//
+	// Deprecated: 配置文件重新载入后不会更新，请使用GetConfig
 	Config   Config
+	config   configHolder
 	bot      *Bot
 	provider Provider
 	Hooks    engineHookManager
+
+	heartbeat *heartbeatWatchdog
+	filters   filterList
+	plugins   pluginHubRegistry // 已加载的插件
+	switches  pluginSwitches    // 插件在各群、各私聊中的开关
+
+	pluginToggleLock sync.Mutex // 同一时间只启用或禁用一个插件
+
+	storage     Storage // 插件的键值存储
+	storageLock sync.RWMutex
+
+	scheduler scheduler // 插件的定时任务
+
+	stopConfigWatch func() // 停止监视配置文件，未开启热重载时为nil
 }
 
 func NewEngine(cfg Config) *Engine {
@@ -46,6 +64,7 @@ func NewEngine(cfg Config) *Engine {
 func NewEngineWithProvider(cfg Config, provider Provider) *Engine {
 	engine := &Engine{}
 	engine.Config = cfg
+	engine.config.store(cfg)
 	engine.Hooks = engineHookManager{
 		hookManager: hookManager{
 			hookMap: make(map[hookType][]pHookFunc),
@@ -57,12 +76,27 @@ func NewEngineWithProvider(cfg Config, provider Provider) *Engine {
 
 	engine.bot = &Bot{}
 	engine.bot.Init(engine.provider)
+	engine.bot.config = &engine.config
+	engine.heartbeat = newHeartbeatWatchdog(engine)
+
+	storage, err := openStorage(cfg.GetBaseConfig().Storage)
+	if err != nil {
+		log.Fatalf("打开存储失败：%s", err)
+	}
+	engine.storage = storage
 
 	// 初始化handler
 	engine.Handler = Handler{
 		subHandlers: make(map[EventName][]*Handler),
 		parent:      nil,
 	}
+	// 先于插件注册，插件管理命令不受插件影响
+	engine.initPluginSwitches()
+
+	// 配置文件中的过滤规则，每次读取以便配置更新后生效
+	engine.AddFilter(func(ev I_Event) bool {
+		return engine.GetConfig().GetBaseConfig().EventFilter.allows(ev)
+	})
 
 	// 通知钩子
 	GlobalHooks.runHook(engineLifecycleHook_EngineCreated, func(phf pHookFunc) {
@@ -70,6 +104,11 @@ func NewEngineWithProvider(cfg Config, provider Provider) *Engine {
 		f(engine)
 	})
 
+	// 配置文件修改后自动重新载入
+	if base := cfg.GetBaseConfig(); base.HotReload && base.path != "" {
+		engine.stopConfigWatch = engine.WatchConfigFile(base.path, defaultConfigWatchInterval)
+	}
+
 	return engine
 }
 
@@ -90,18 +129,38 @@ func (engine *Engine) Run() {
 
 	wg := sync.WaitGroup{}
 	eventCnt := int64(0)
+	recallsRestored := false
 MSG_LOOP:
 	for {
 		select {
 		case ev := <-eventCh:
+			engine.bot.recordSentEvent(ev)
 			if ev.GetPostType() == PostType_MetaEvent {
+				if ev, ok := ev.(*HeartbeatMetaEvent); ok {
+					engine.heartbeat.beat(ev)
+				}
 				if ev, ok := ev.(*LifeCycleMetaEvent); ok {
 					engine.bot.selfId = ev.SelfId
+					engine.bot.setOnline(true)
+					// 连上协议端后，继续上次退出时未完成的撤回
+					if path := engine.GetConfig().GetBaseConfig().RecallPersistFile; path != "" && !recallsRestored {
+						recallsRestored = true
+						if err := engine.bot.recalls.restore(path); err != nil {
+							log.Errorf("恢复等待撤回的消息失败: %s", err)
+						}
+					}
 				}
-			} else {
+			}
+			filtered := engine.filterEvent(ev)
+			if filtered {
+				log.Debugf("已过滤：%s", ev.GetEventDescription())
+			} else if ev.GetPostType() != PostType_MetaEvent {
 				log.Info(ev.GetEventDescription())
 			}
 			engine.Hooks.fireEventHook(eventLifecycleHook_EventRecieved, ev)
+			if filtered {
+				continue
+			}
 
 			ctx := newContext(ev, engine)
 			wg.Add(1)
@@ -112,6 +171,8 @@ MSG_LOOP:
 					atomic.AddInt64(&eventCnt, -1)
 				}()
 				defer engine.Hooks.fireEventHook(eventLifecycleHook_EventHandled, ev)
+				// 可能需要调用API查询被回复的消息，不在这里阻塞事件的接收
+				engine.detectToMe(ev)
 				engine.handleEvent(ctx)
 			}()
 
@@ -121,7 +182,11 @@ MSG_LOOP:
 		}
 	}
 
-	engine.provider.Stop()
+	// 不再处理新事件，但仍需取走，以免阻塞Provider，导致清理工作中无法调用API
+	go func() {
+		for range eventCh {
+		}
+	}()
 
 	if eventCnt > 0 {
 		log.Infof("等待剩余%d个消息处理完成，Ctrl+C以强制跳过", eventCnt)
@@ -143,6 +208,22 @@ MSG_LOOP:
 		f := *phf.(*EngineHookCallback)
 		f(engine)
 	})
+	engine.terminate()
+
+	engine.provider.Stop()
+}
+
+// 退出前的清理：处理还未撤回的消息，停止后台任务，关闭存储
+func (engine *Engine) terminate() {
+	engine.bot.recalls.terminate(engine.GetConfig())
+	engine.heartbeat.stop()
+	engine.scheduler.stop()
+	if engine.stopConfigWatch != nil {
+		engine.stopConfigWatch()
+	}
+	if err := engine.getStorage().Close(); err != nil {
+		log.Errorf("关闭存储失败：%s", err)
+	}
 }
 
 type providerRegistry map[string]Provider
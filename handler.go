@@ -3,9 +3,14 @@ package gonebot
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 
 	goarg "github.com/alexflint/go-arg"
 )
@@ -19,8 +24,15 @@ type Handler struct {
 	parent      *Handler
 	subHandlers map[EventName][]*Handler
 	mu          sync.RWMutex
+
+	unloadSignal chan struct{} // 插件的根Handler才有，插件被禁用时关闭
+	hub          *PluginHub    // 插件的根Handler才有，所属的插件
+	seq          uint64        // 创建的顺序，同级的Handler按此排列
 }
 
+// 最近创建的Handler的序号
+var handlerSeq atomic.Uint64
+
 // 使用中间件
 func (h *Handler) Use(middlewares ...Middleware) *Handler {
 	h.mu.Lock()
@@ -35,14 +47,35 @@ func (h *Handler) Handle(f HandlerFunc) {
 	h.handleFunc = f
 }
 
+// 新建一个尚未添加到任何Handler上的Handler。配置好中间件与处理函数后再添加，以免先收到事件
+func newHandler() *Handler {
+	return &Handler{
+		subHandlers: make(map[EventName][]*Handler),
+		seq:         handlerSeq.Add(1),
+	}
+}
+
+// 是否没有子Handler
+func (h *Handler) isLeaf() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subHandlers) == 0
+}
+
 // 添加子Handler
 func (h *Handler) addSubHandler(subHandler *Handler, eventType ...EventName) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// 按创建顺序插入，被移除的Handler重新添加时回到原来的位置
 	subHandler.parent = h
 	for _, event := range eventType {
-		h.subHandlers[event] = append(h.subHandlers[event], subHandler)
+		list := h.subHandlers[event]
+		i := sort.Search(len(list), func(i int) bool { return list[i].seq > subHandler.seq })
+		list = append(list, nil)
+		copy(list[i+1:], list[i:])
+		list[i] = subHandler
+		h.subHandlers[event] = list
 	}
 }
 
@@ -68,10 +101,7 @@ func (h *Handler) removeSubHandler(handler *Handler, eventType ...EventName) {
 //
 // 调用remove方法可以删除当前Handler。
 func (h *Handler) NewRemovableHandler(eventTypes ...EventName) (handler *Handler, remove func()) {
-	handler = &Handler{
-		parent:      h,
-		subHandlers: make(map[EventName][]*Handler),
-	}
+	handler = newHandler()
 	if len(eventTypes) == 0 {
 		eventTypes = append(eventTypes, EventName_AllEvent)
 	}
@@ -81,12 +111,88 @@ func (h *Handler) NewRemovableHandler(eventTypes ...EventName) (handler *Handler
 	}
 }
 
+// 所属插件被禁用时关闭的通道。不属于任何插件时返回nil
+func (h *Handler) pluginUnloadSignal() <-chan struct{} {
+	for cur := h; cur != nil; cur = cur.parent {
+		cur.mu.RLock()
+		ch := cur.unloadSignal
+		cur.mu.RUnlock()
+		if ch != nil {
+			return ch
+		}
+	}
+	return nil
+}
+
 // 新建一个Handler，用于处理指定类型的事件
 func (h *Handler) NewHandler(eventTypes ...EventName) (handler *Handler) {
 	nh, _ := h.NewRemovableHandler(eventTypes...)
 	return nh
 }
 
+// 可以创建子Handler的对象，即*Handler与*PluginHub
+type HandlerParent interface {
+	NewHandler(eventTypes ...EventName) *Handler
+}
+
+// 新建一个Handler，处理类型为T的事件，事件类型由T推导，无需再手动指定事件名称。例如：
+//
+//	gonebot.HandleEvent(hub, func(ctx *gonebot.Context, ev *gonebot.GroupIncreaseNoticeEvent) {
+//		ctx.Reply("欢迎新人")
+//	}, gonebot.FromGroup(123456))
+//
+// T为具体的事件结构体指针时，只处理注册为该类型的事件；T为接口时处理所有实现了该接口的事件。
+// T未被注册为任何事件类型时会panic。
+func HandleEvent[T I_Event](parent HandlerParent, f func(ctx *Context, ev T), middlewares ...Middleware) *Handler {
+	handler := parent.NewHandler(eventNamesOfType(reflect.TypeOf((*T)(nil)).Elem())...)
+	handler.Use(middlewares...)
+	handler.Handle(func(ctx *Context) {
+		// 自定义事件类型可能覆盖了内置类型，断言失败时交给下一个Handler
+		ev, ok := ctx.Event.(T)
+		if !ok {
+			ctx.Next()
+			return
+		}
+		f(ctx, ev)
+	})
+	return handler
+}
+
+// 找出注册为typ的事件名称。若某名称是另一名称的子类型，只保留较短的那个，避免同一事件被处理两次
+func eventNamesOfType(typ reflect.Type) []EventName {
+	if typ.Kind() == reflect.Interface {
+		return []EventName{EventName_AllEvent}
+	}
+
+	eventTypeLock.RLock()
+	names := []string{}
+	for name, prototype := range eventTypeMap {
+		if reflect.TypeOf(prototype) == typ {
+			names = append(names, name)
+		}
+	}
+	eventTypeLock.RUnlock()
+	if len(names) == 0 {
+		panic(fmt.Sprintf("%s未被注册为事件类型", typ))
+	}
+
+	sort.Strings(names)
+	ret := []EventName{}
+	for _, name := range names {
+		covered := false
+		for _, other := range ret {
+			if strings.HasPrefix(name, string(other)+".") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			ret = append(ret, EventName(name))
+		}
+	}
+	return ret
+}
+
 func (h *Handler) getMatchedHandler(eventName EventName) (handlers []*Handler) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -148,7 +254,7 @@ func (proc *process) nextHandler() bool {
 	proc.curHandler = proc.handlerQueue[0]
 	proc.handlerQueue = proc.handlerQueue[1:]
 	proc.middlewares = proc.curHandler.middlewares
-	proc.isLeaf = len(proc.curHandler.subHandlers) == 0
+	proc.isLeaf = proc.curHandler.isLeaf()
 	proc.mwIdx = 0
 	proc.done = false
 	proc.shouldExpand = true
@@ -200,7 +306,7 @@ handlerLoop:
 			// 提前设置done，让下次循环能正确获取下一个Handler。
 			// 否则会造成无限递归
 			proc.done = true
-			proc.curHandler.handleFunc(proc.ctx)
+			proc.curHandler.callHandleFunc(proc.ctx)
 			proc.processedByHandler = true
 		}
 		proc.done = true
@@ -385,9 +491,6 @@ func FromOwnerOrHigher() Middleware {
 // 仅超管，群聊和私聊都可
 func FromSuperuser() Middleware {
 	return func(ctx *Context) bool {
-		config := ctx.Engine.Config
-		sus := config.GetBaseConfig().Superuser
-
 		var senderId int64
 		if ev, ok := ctx.Event.(*GroupMessageEvent); ok {
 			senderId = ev.Sender.UserId
@@ -396,20 +499,25 @@ func FromSuperuser() Middleware {
 		} else {
 			return false
 		}
+		return ctx.Engine.IsSuperuser(senderId)
+	}
+}
 
-		for _, su := range sus {
-			if su == senderId {
-				return true
-			}
+// 用户是否为配置中的超级用户
+func (engine *Engine) IsSuperuser(userId int64) bool {
+	for _, su := range engine.GetConfig().GetBaseConfig().Superuser {
+		if su == userId {
+			return true
 		}
-		return false
 	}
+	return false
 }
 
 type prefixMatchResult struct {
-	Matched string // 匹配到的前缀
-	Remain  string // 去除前缀后的剩下文本
-	Raw     string // 原始文本
+	Matched   string  // 匹配到的前缀
+	Remain    string  // 去除前缀后的剩下文本
+	RemainMsg Message // 去除前缀后剩下的消息，保留图片等非文本消息段，开头At机器人的消息段会被去除
+	Raw       string  // 原始文本
 }
 
 // 事件为MessageEvent，且消息以某个前缀开头
@@ -428,15 +536,32 @@ func StartsWith(prefix ...string) Middleware {
 		}
 
 		ctx.Set("prefix", &prefixMatchResult{
-			Matched: find,
-			Remain:  strings.TrimPrefix(msgText, find),
-			Raw:     msgText,
+			Matched:   find,
+			Remain:    strings.TrimPrefix(msgText, find),
+			RemainMsg: remainMessageAfterPrefix(ctx, find),
+			Raw:       msgText,
 		})
 
 		return true
 	}
 }
 
+// 截取消息中位于开头文本matched之后的部分，并去除首尾空白
+func remainMessageAfterPrefix(ctx *Context, matched string) Message {
+	msg := *ctx.Event.GetMessage()
+	if ctx.Bot != nil {
+		msg, _ = msg.StripLeadingAt(ctx.Bot.GetSelfId())
+	}
+
+	// 匹配时使用的文本去除了开头的空白，截取时需要跳过
+	raw := ""
+	for _, seg := range msg.FilterByType("text") {
+		raw += fmt.Sprint(seg.Data["text"])
+	}
+	lead := utf8.RuneCountInString(raw) - utf8.RuneCountInString(strings.TrimLeftFunc(raw, unicode.IsSpace))
+	return msg.SliceText(lead+utf8.RuneCountInString(matched), -1).TrimSpace()
+}
+
 // 获取StartsWith匹配结果
 func (ctx *Context) GetPrefixMatchResult() *prefixMatchResult {
 	if v, ok := ctx.Get("prefix"); ok {
@@ -488,6 +613,7 @@ type commandMatchResult struct {
 	Command   string   // 匹配到的命令
 	Args      []string // 命令参数，以空格分割
 	Remain    string   // 去除命令后的剩下文本
+	RemainMsg Message  // 去除命令后剩下的消息，保留图片等非文本消息段，开头At机器人的消息段会被去除
 	Raw       string   // 原始文本
 }
 
@@ -499,7 +625,7 @@ func Command(cmd ...string) Middleware {
 			return false
 		}
 
-		cmdPrefixs := ctx.Engine.Config.GetBaseConfig().CmdPrefix
+		cmdPrefixs := ctx.Engine.GetConfig().GetBaseConfig().CmdPrefix
 		msgText := e.ExtractPlainText()
 		reg := regexp.MustCompile(fmt.Sprintf("^(%s)(%s)", strings.Join(cmdPrefixs, "|"), strings.Join(cmd, "|")))
 		find := reg.FindStringSubmatch(msgText)
@@ -520,6 +646,7 @@ func Command(cmd ...string) Middleware {
 			Command:   find[2],
 			Args:      argsFiltered,
 			Remain:    remain,
+			RemainMsg: remainMessageAfterPrefix(ctx, find[0]),
 			Raw:       msgText,
 		})
 
@@ -1,5 +1,7 @@
 package gonebot
 
+import "testing"
+
 /*
 	TODO
 	等到写完Mock后再来补这个测试吧
@@ -341,3 +343,22 @@ package gonebot
 // 		t.Error("handleEvent error", ret)
 // 	}
 // }
+
+func Test_IsSuperuser(t *testing.T) {
+	engine := NewEngineWithProvider(&BaseConfig{Superuser: []int64{10, 20}}, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	tests := []struct {
+		userId int64
+		want   bool
+	}{
+		{10, true},
+		{20, true},
+		{11, false},
+		{0, false},
+	}
+	for _, tt := range tests {
+		if got := engine.IsSuperuser(tt.userId); got != tt.want {
+			t.Errorf("IsSuperuser(%d) = %v, want %v", tt.userId, got, tt.want)
+		}
+	}
+}
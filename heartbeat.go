@@ -0,0 +1,113 @@
+package gonebot
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 默认连续多少个心跳间隔未收到心跳，视为与协议端失去联系
+const defaultHeartbeatTimeout = 3
+
+// 支持主动重连的Provider。心跳丢失时，框架会调用Reconnect断开当前连接并重新连接
+type Reconnector interface {
+	Reconnect()
+}
+
+// 心跳看门狗。根据心跳事件中的间隔，在连续若干个间隔未收到心跳时判定为掉线
+type heartbeatWatchdog struct {
+	engine *Engine
+
+	mu            sync.Mutex
+	interval      time.Duration // 最近一次心跳上报的间隔
+	lastHeartbeat time.Time
+	lost          bool // 是否处于心跳丢失状态
+	timer         *time.Timer
+	stopped       bool
+}
+
+func newHeartbeatWatchdog(engine *Engine) *heartbeatWatchdog {
+	return &heartbeatWatchdog{engine: engine}
+}
+
+// 超时前允许错过的心跳个数，小于0时不检测
+func (w *heartbeatWatchdog) timeoutCount() int {
+	n := w.engine.GetConfig().GetBaseConfig().HeartbeatTimeout
+	if n == 0 {
+		return defaultHeartbeatTimeout
+	}
+	return n
+}
+
+// 收到心跳
+func (w *heartbeatWatchdog) beat(ev *HeartbeatMetaEvent) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.lastHeartbeat = time.Now()
+	if ev.Interval > 0 {
+		w.interval = time.Duration(ev.Interval) * time.Millisecond
+	}
+	recovered := w.lost
+	w.lost = false
+	w.resetTimer()
+	last := w.lastHeartbeat
+	w.mu.Unlock()
+
+	w.engine.bot.setOnline(true)
+	if recovered {
+		log.Info("已重新收到协议端的心跳")
+		w.engine.Hooks.fireHeartbeatHook(heartbeatHook_HeartbeatRecovered, last)
+	}
+}
+
+// 重新开始计时，需持有锁
+func (w *heartbeatWatchdog) resetTimer() {
+	n := w.timeoutCount()
+	if n < 0 || w.interval <= 0 {
+		return
+	}
+	timeout := w.interval * time.Duration(n)
+	if w.timer == nil {
+		w.timer = time.AfterFunc(timeout, w.timeout)
+	} else {
+		w.timer.Reset(timeout)
+	}
+}
+
+// 超时未收到心跳
+func (w *heartbeatWatchdog) timeout() {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	firstLost := !w.lost
+	w.lost = true
+	last := w.lastHeartbeat
+	// 重连后仍未恢复的话，下个周期再次重连
+	w.resetTimer()
+	w.mu.Unlock()
+
+	if firstLost {
+		log.Warnf("已有%s未收到协议端的心跳，判定为掉线", time.Since(last).Round(time.Second))
+		w.engine.bot.setOnline(false)
+		w.engine.Hooks.fireHeartbeatHook(heartbeatHook_HeartbeatLost, last)
+	}
+	if r, ok := w.engine.provider.(Reconnector); ok {
+		log.Info("正在尝试重新连接协议端")
+		r.Reconnect()
+	}
+}
+
+func (w *heartbeatWatchdog) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
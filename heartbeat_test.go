@@ -0,0 +1,44 @@
+package gonebot
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type reconnectingProvider struct {
+	recordingProvider
+	reconnects int32
+}
+
+func (p *reconnectingProvider) Reconnect() {
+	atomic.AddInt32(&p.reconnects, 1)
+}
+
+func Test_HeartbeatWatchdog(t *testing.T) {
+	provider := &reconnectingProvider{}
+	engine := NewEngineWithProvider(&BaseConfig{HeartbeatTimeout: 2}, provider)
+	defer engine.heartbeat.stop()
+
+	var lost, recovered int32
+	engine.Hooks.HeartbeatLost(func(time.Time) { atomic.AddInt32(&lost, 1) })
+	engine.Hooks.HeartbeatRecovered(func(time.Time) { atomic.AddInt32(&recovered, 1) })
+
+	beat := &HeartbeatMetaEvent{Interval: 10}
+	engine.heartbeat.beat(beat)
+	if !engine.bot.IsOnline() {
+		t.Error("收到心跳后应为在线")
+	}
+
+	// 超时后判定掉线并重连，持续掉线时钩子只触发一次，重连会继续尝试
+	time.Sleep(70 * time.Millisecond)
+	if engine.bot.IsOnline() || atomic.LoadInt32(&lost) != 1 || atomic.LoadInt32(&provider.reconnects) < 2 {
+		t.Errorf("心跳丢失处理错误：online=%v, lost=%d, reconnects=%d",
+			engine.bot.IsOnline(), lost, provider.reconnects)
+	}
+
+	engine.heartbeat.beat(beat)
+	if !engine.bot.IsOnline() || atomic.LoadInt32(&recovered) != 1 {
+		t.Error("重新收到心跳后应恢复在线")
+	}
+}
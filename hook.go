@@ -1,5 +1,7 @@
 package gonebot
 
+import "time"
+
 // 任意函数类型的指针
 type pHookFunc interface{}
 
@@ -75,6 +77,8 @@ func (eh *globalHookManager) EngineWillTerminate(f EngineHookCallback) (cancel f
 const (
 	pluginLifecycleHook_PluginWillLoad hookType = iota + 2000
 	pluginLifecycleHook_PluginLoaded
+	pluginLifecycleHook_PluginWillUnload
+	pluginLifecycleHook_PluginUnloaded
 )
 
 type PluginHookCallback func(*PluginHub)
@@ -85,16 +89,26 @@ func (eh *globalHookManager) firePluginHook(hookType hookType, hub *PluginHub) {
 	})
 }
 
-// 每个插件即将加载时触发
+// 每个插件即将加载时触发。运行时重新启用插件时也会触发
 func (eh *globalHookManager) PluginWillLoad(f PluginHookCallback) (cancel func()) {
 	return eh.addHook(pluginLifecycleHook_PluginWillLoad, &f)
 }
 
-// 每个插件加载完毕时触发
+// 每个插件加载完毕时触发。运行时重新启用插件后也会触发
 func (eh *globalHookManager) PluginLoaded(f PluginHookCallback) (cancel func()) {
 	return eh.addHook(pluginLifecycleHook_PluginLoaded, &f)
 }
 
+// 插件在运行时被禁用，即将卸载时触发
+func (eh *globalHookManager) PluginWillUnload(f PluginHookCallback) (cancel func()) {
+	return eh.addHook(pluginLifecycleHook_PluginWillUnload, &f)
+}
+
+// 插件卸载完毕时触发
+func (eh *globalHookManager) PluginUnloaded(f PluginHookCallback) (cancel func()) {
+	return eh.addHook(pluginLifecycleHook_PluginUnloaded, &f)
+}
+
 /*
  * 以下为挂在Engine上的钩子，需要通过engine.Hooks访问
  */
@@ -127,3 +141,45 @@ func (eh *engineHookManager) EventRecieved(f EventHookCallback) (cancel func())
 func (eh *engineHookManager) EventHandled(f EventHookCallback) (cancel func()) {
 	return eh.addHook(eventLifecycleHook_EventHandled, &f)
 }
+
+// 参数为最后一次收到心跳的时间
+type HeartbeatHookCallback func(lastHeartbeat time.Time)
+
+// 心跳状态
+const (
+	heartbeatHook_HeartbeatLost hookType = iota + 4000
+	heartbeatHook_HeartbeatRecovered
+)
+
+func (eh *engineHookManager) fireHeartbeatHook(hookType hookType, lastHeartbeat time.Time) {
+	eh.runHook(hookType, func(hook pHookFunc) {
+		(*hook.(*HeartbeatHookCallback))(lastHeartbeat)
+	})
+}
+
+// 连续若干个心跳间隔未收到心跳时触发，之后在恢复前不会重复触发
+func (eh *engineHookManager) HeartbeatLost(f HeartbeatHookCallback) (cancel func()) {
+	return eh.addHook(heartbeatHook_HeartbeatLost, &f)
+}
+
+// 心跳丢失后重新收到心跳时触发
+func (eh *engineHookManager) HeartbeatRecovered(f HeartbeatHookCallback) (cancel func()) {
+	return eh.addHook(heartbeatHook_HeartbeatRecovered, &f)
+}
+
+type ConfigHookCallback func(oldConfig, newConfig Config)
+
+const (
+	configHook_ConfigReloaded hookType = iota + 5000
+)
+
+func (eh *engineHookManager) fireConfigHook(hookType hookType, oldConfig, newConfig Config) {
+	eh.runHook(hookType, func(hook pHookFunc) {
+		(*hook.(*ConfigHookCallback))(oldConfig, newConfig)
+	})
+}
+
+// 配置文件重新载入后触发，此时插件的配置结构体已更新
+func (eh *engineHookManager) ConfigReloaded(f ConfigHookCallback) (cancel func()) {
+	return eh.addHook(configHook_ConfigReloaded, &f)
+}
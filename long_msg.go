@@ -0,0 +1,300 @@
+package gonebot
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 长消息的发送方式
+type LongMessageMode string
+
+const (
+	LongMessageMode_Split   LongMessageMode = "split"   // 分割成多条消息依次发送
+	LongMessageMode_Forward LongMessageMode = "forward" // 分割后作为合并转发消息发送
+	LongMessageMode_Page    LongMessageMode = "page"    // 分页发送，用户回复“下一页”后发送下一页。仅在回复时可用，其余情况按split处理
+)
+
+// 长消息的分割选项，零值字段使用默认值
+type LongMessageOptions struct {
+	Auto        bool            `yaml:"auto"`         // 使用Reply系列方法回复时，是否自动分割超长消息
+	Mode        LongMessageMode `yaml:"mode"`         // 发送方式，默认split
+	MaxChars    int             `yaml:"max_chars"`    // 每条消息最多的文字数，默认1500
+	MaxSegments int             `yaml:"max_segments"` // 每条消息最多的消息段数，默认20
+	ForwardName string          `yaml:"forward_name"` // 合并转发时，节点显示的昵称，默认“消息”
+	PageTimeout int             `yaml:"page_timeout"` // 分页时等待翻页的超时时间，单位秒，默认60
+}
+
+// 用于翻页的消息
+var nextPageWords = []string{"下一页", "next"}
+
+func (opt LongMessageOptions) withDefaults() LongMessageOptions {
+	if opt.Mode == "" {
+		opt.Mode = LongMessageMode_Split
+	}
+	if opt.MaxChars == 0 {
+		opt.MaxChars = 1500
+	}
+	if opt.MaxSegments == 0 {
+		opt.MaxSegments = 20
+	}
+	if opt.ForwardName == "" {
+		opt.ForwardName = "消息"
+	}
+	if opt.PageTimeout == 0 {
+		opt.PageTimeout = 60
+	}
+	return opt
+}
+
+// 获取长消息选项，opt为nil时使用配置文件中的设置
+func resolveLongMessageOptions(cfg Config, opt *LongMessageOptions) LongMessageOptions {
+	if opt != nil {
+		return opt.withDefaults()
+	}
+	if cfg != nil {
+		return cfg.GetBaseConfig().LongMessage.withDefaults()
+	}
+	return LongMessageOptions{}.withDefaults()
+}
+
+// 按文字数和消息段数的限制将消息分割为多条。
+//
+// 文本消息段优先在换行处切开，单行超长时才会从中间截断；其他消息段不会被拆开。
+// 限制值小于等于0表示不限制。
+func (m Message) Split(maxChars, maxSegments int) []Message {
+	s := messageSplitter{maxChars: maxChars, maxSegments: maxSegments}
+	for _, seg := range m {
+		if seg.IsText() {
+			s.addText(fmt.Sprint(seg.Data["text"]))
+		} else {
+			s.addSegment(seg)
+		}
+	}
+	s.flush()
+	return s.result
+}
+
+type messageSplitter struct {
+	maxChars    int
+	maxSegments int
+
+	result []Message
+	cur    Message // 正在填充的一条消息
+	chars  int     // cur中的文字数
+}
+
+// 结束当前这条消息
+func (s *messageSplitter) flush() {
+	if len(s.cur) > 0 {
+		s.result = append(s.result, s.cur)
+	}
+	s.cur = nil
+	s.chars = 0
+}
+
+// 在换行处切开时，去掉作为分割点的那个换行
+func (s *messageSplitter) trimSplitNewline() {
+	n := len(s.cur)
+	if n == 0 || !s.cur[n-1].IsText() {
+		return
+	}
+	text := s.cur[n-1].Data["text"].(string)
+	if !strings.HasSuffix(text, "\n") {
+		return
+	}
+	if text = text[:len(text)-1]; text == "" {
+		s.cur = s.cur[:n-1]
+	} else {
+		s.cur[n-1] = MsgFactory.Text(text)
+	}
+}
+
+func (s *messageSplitter) addSegment(seg MessageSegment) {
+	if s.maxSegments > 0 && len(s.cur) >= s.maxSegments {
+		s.flush()
+	}
+	s.cur = append(s.cur, seg)
+}
+
+func (s *messageSplitter) appendText(text string) {
+	n := len(s.cur)
+	if n > 0 && s.cur[n-1].IsText() {
+		// 与前一个文本消息段合并，不占用新的消息段
+		s.cur[n-1] = MsgFactory.Text(s.cur[n-1].Data["text"].(string) + text)
+	} else {
+		s.addSegment(MsgFactory.Text(text))
+	}
+	s.chars += utf8.RuneCountInString(text)
+}
+
+func (s *messageSplitter) addText(text string) {
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for line != "" {
+			n := utf8.RuneCountInString(line)
+			if s.maxChars <= 0 || s.chars+n <= s.maxChars {
+				s.appendText(line)
+				break
+			}
+			// 当前这条已有内容，放到下一条再试
+			if s.chars > 0 {
+				s.trimSplitNewline()
+				s.flush()
+				continue
+			}
+			// 单行就超长了，只能截断
+			runes := []rune(line)
+			s.appendText(string(runes[:s.maxChars]))
+			line = string(runes[s.maxChars:])
+		}
+	}
+}
+
+// 将分割后的多条消息构造为合并转发的节点
+func buildForwardNodes(chunks []Message, selfId int64, name string) Message {
+	nodes := make(Message, 0, len(chunks))
+	for _, chunk := range chunks {
+		nodes = append(nodes, MsgFactory.NodeCustom(selfId, name, chunk))
+	}
+	return nodes
+}
+
+// 发送长消息到私聊，超出限制时按opt分割发送，opt为nil时使用配置文件中的设置。返回所有已发送消息的ID
+func (bot *Bot) SendPrivateLongMsg(userId int64, message Message, opt *LongMessageOptions) ([]int32, error) {
+	return bot.sendLongMsg("private", userId, message, opt)
+}
+
+// 发送长消息到群聊，超出限制时按opt分割发送，opt为nil时使用配置文件中的设置。返回所有已发送消息的ID
+func (bot *Bot) SendGroupLongMsg(groupId int64, message Message, opt *LongMessageOptions) ([]int32, error) {
+	return bot.sendLongMsg("group", groupId, message, opt)
+}
+
+func (bot *Bot) sendLongMsg(messageType string, targetId int64, message Message, opt *LongMessageOptions) ([]int32, error) {
+	o := resolveLongMessageOptions(bot.config.load(), opt)
+	chunks := message.Split(o.MaxChars, o.MaxSegments)
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	send := bot.SendPrivateMsg
+	sendForward := bot.SendPrivateForwardMsg
+	if messageType == "group" {
+		send = bot.SendGroupMsg
+		sendForward = bot.SendGroupForwardMsg
+	}
+
+	if o.Mode == LongMessageMode_Forward && len(chunks) > 1 {
+		id, err := sendForward(targetId, buildForwardNodes(chunks, bot.selfId, o.ForwardName))
+		if err != nil {
+			return nil, err
+		}
+		return []int32{id}, nil
+	}
+
+	ids := make([]int32, 0, len(chunks))
+	for _, chunk := range chunks {
+		id, err := send(targetId, chunk, false)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// 回复长消息，超出限制时按opt分割发送，opt为nil时使用配置文件中的设置
+func (ctx *Context) ReplyLong(msg Message, opt *LongMessageOptions) (err error) {
+	var cfg Config
+	if ctx.Engine != nil {
+		cfg = ctx.Engine.GetConfig()
+	}
+	o := resolveLongMessageOptions(cfg, opt)
+	chunks := msg.Split(o.MaxChars, o.MaxSegments)
+	if len(chunks) <= 1 {
+		return ctx.replyBasic(msg, nil)
+	}
+	return ctx.replyChunks(chunks, o)
+}
+
+// 回复已经分好页的消息，按opt.Mode发送，opt为nil时使用配置文件中的设置。只有一页时直接回复
+func (ctx *Context) ReplyPages(pages []Message, opt *LongMessageOptions) error {
+	var cfg Config
+	if ctx.Engine != nil {
+		cfg = ctx.Engine.GetConfig()
+	}
+	switch len(pages) {
+	case 0:
+		return nil
+	case 1:
+		return ctx.replyBasic(pages[0], nil)
+	}
+	return ctx.replyChunks(pages, resolveLongMessageOptions(cfg, opt))
+}
+
+func (ctx *Context) replyChunks(chunks []Message, o LongMessageOptions) (err error) {
+	switch o.Mode {
+	case LongMessageMode_Forward:
+		return ctx.replyForward(chunks, o)
+	case LongMessageMode_Page:
+		return ctx.replyPaged(chunks, o)
+	default:
+		// 仅第一条消息At发送者
+		for i, chunk := range chunks {
+			var params quickOperationParams
+			if i > 0 {
+				params = quickOperationParams{"at_sender": false}
+			}
+			if err = ctx.replyBasic(chunk, params); err != nil {
+				return
+			}
+		}
+		return
+	}
+}
+
+// 以合并转发的形式回复
+func (ctx *Context) replyForward(chunks []Message, opt LongMessageOptions) (err error) {
+	nodes := buildForwardNodes(chunks, ctx.Bot.GetSelfId(), opt.ForwardName)
+	switch ev := ctx.Event.(type) {
+	case *GroupMessageEvent:
+		_, err = ctx.Bot.SendGroupForwardMsg(ev.GroupId, nodes)
+	case *PrivateMessageEvent:
+		_, err = ctx.Bot.SendPrivateForwardMsg(ev.UserId, nodes)
+	default:
+		log.Warnf("该事件不是私聊或群聊消息事件，无法回复合并转发消息。(类型%s)", ctx.Event.GetEventName())
+		return
+	}
+	if err != nil {
+		log.Errorf("回复合并转发消息失败: %s", err.Error())
+	}
+	return
+}
+
+// 分页回复，每页末尾附上页码，等待同一Session回复“下一页”后再发送下一页
+func (ctx *Context) replyPaged(chunks []Message, opt LongMessageOptions) (err error) {
+	for i, chunk := range chunks {
+		page := append(Message{}, chunk...)
+		if i < len(chunks)-1 {
+			page.AppendText(fmt.Sprintf("\n（第%d/%d页，发送“%s”查看下一页）", i+1, len(chunks), nextPageWords[0]))
+		} else {
+			page.AppendText(fmt.Sprintf("\n（第%d/%d页）", i+1, len(chunks)))
+		}
+		var params quickOperationParams
+		if i > 0 {
+			params = quickOperationParams{"at_sender": false}
+		}
+		if err = ctx.replyBasic(page, params); err != nil {
+			return
+		}
+
+		if i < len(chunks)-1 {
+			ev := ctx.WaitForNextEventInSameSession(opt.PageTimeout, FullMatch(nextPageWords...))
+			if ev == nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package gonebot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_ReplyPages(t *testing.T) {
+	pages := func(n int) []Message {
+		ret := []Message{}
+		for i := 0; i < n; i++ {
+			ret = append(ret, MsgPrint(fmt.Sprint(i)))
+		}
+		return ret
+	}
+	tests := []struct {
+		name  string
+		pages []Message
+		mode  LongMessageMode
+		want  []string
+	}{
+		{"没有内容", pages(0), LongMessageMode_Forward, []string{}},
+		{"只有一页时直接回复", pages(1), LongMessageMode_Forward, []string{".handle_quick_operation"}},
+		{"合并转发", pages(2), LongMessageMode_Forward, []string{"send_group_forward_msg"}},
+		{"分条发送", pages(2), LongMessageMode_Split, []string{".handle_quick_operation", ".handle_quick_operation"}},
+	}
+	for _, tt := range tests {
+		provider := &recordingProvider{}
+		engine := NewEngineWithProvider(&BaseConfig{}, provider)
+		engine.heartbeat.stop()
+		ev := &GroupMessageEvent{}
+		ev.PostType = PostType_MessageEvent
+		ev.MessageType = "group"
+		ev.GroupId = 1
+		ctx := newContext(ev, engine)
+		if err := ctx.ReplyPages(tt.pages, &LongMessageOptions{Mode: tt.mode}); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		if routes := provider.routes(); fmt.Sprint(routes) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: 应调用%v，实际调用了%v", tt.name, tt.want, routes)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package gonebot
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
 
@@ -76,7 +81,7 @@ func (m *Message) ExtendSegmentArray(segs []MessageSegment) {
 func (m Message) ExtractPlainText() (text string) {
 	for _, seg := range m {
 		if seg.IsText() {
-			text += seg.Data["text"].(string)
+			text += fmt.Sprint(seg.Data["text"])
 		}
 	}
 	text = strings.TrimSpace(text)
@@ -235,9 +240,8 @@ func MsgMustPrintf(tmpl string, args ...interface{}) (msg Message) {
 }
 
 func convertJsonObjectToMessageSegment(m gjson.Result) (seg MessageSegment) {
-	seg.Type = m.Get("type").String()
-	if m.Get("data").Exists() {
-		seg.Data = m.Get("data").Value().(map[string]interface{})
+	if err := json.Unmarshal([]byte(m.Raw), &seg); err != nil {
+		log.Warnf("解析消息段失败：%s", err)
 	}
 	return
 }
@@ -249,3 +253,263 @@ func ConvertJsonArrayToMessage(m []gjson.Result) (msg Message) {
 	}
 	return
 }
+
+// 从JSON中生成Message，兼容数组格式和字符串格式（CQ码）。CQ码无法解析时，整体作为纯文本
+func ConvertJsonToMessage(m gjson.Result) Message {
+	if m.Type == gjson.String {
+		return parseCQStringOrText(m.String())
+	}
+	return ConvertJsonArrayToMessage(m.Array())
+}
+
+// 反序列化消息，兼容数组格式和字符串格式（CQ码）
+func (m *Message) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*m = parseCQStringOrText(s)
+		return nil
+	}
+
+	var segs []MessageSegment
+	if err := json.Unmarshal(data, &segs); err != nil {
+		return err
+	}
+	*m = segs
+	return nil
+}
+
+func parseCQStringOrText(s string) Message {
+	msg, err := ParseCQString(s)
+	if err != nil {
+		log.Warnf("%s，将作为纯文本处理", err)
+		return Message{MsgFactory.Text(s)}
+	}
+	return msg
+}
+
+// 将CQ码字符串解析为Message，文本与参数值会被反转义。
+//
+// CQ码不完整（缺少右括号）、类型为空、参数缺少等号等情况将返回错误。
+// 不属于CQ码的方括号视为普通文本。
+func ParseCQString(s string) (msg Message, err error) {
+	msg = Message{}
+	text := strings.Builder{}
+	flushText := func() {
+		if text.Len() > 0 {
+			msg.AppendText(Unescape(text.String()))
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if !strings.HasPrefix(s[i:], "[CQ:") {
+			text.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexAny(s[i+1:], "[]")
+		if end < 0 || s[i+1+end] != ']' {
+			return nil, fmt.Errorf("%w：位置%d的CQ码缺少右括号", ErrInvalidCQCode, i)
+		}
+		end += i + 1
+
+		seg, e := parseCQCode(s[i+len("[CQ:") : end])
+		if e != nil {
+			return nil, fmt.Errorf("%w：位置%d，%s", ErrInvalidCQCode, i, e)
+		}
+		flushText()
+		msg.AppendSegment(seg)
+		i = end + 1
+	}
+	flushText()
+	return
+}
+
+// 解析CQ码方括号内“CQ:”之后的部分，形如“type,k1=v1,k2=v2”
+func parseCQCode(code string) (seg MessageSegment, err error) {
+	parts := strings.Split(code, ",")
+	seg.Type = parts[0]
+	if seg.Type == "" {
+		err = fmt.Errorf("类型为空")
+		return
+	}
+	for _, c := range seg.Type {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+			err = fmt.Errorf("类型%q含有非法字符", seg.Type)
+			return
+		}
+	}
+
+	seg.Data = msgSegData{}
+	for _, param := range parts[1:] {
+		k, v, found := strings.Cut(param, "=")
+		if !found || k == "" {
+			err = fmt.Errorf("参数%q格式错误", param)
+			return
+		}
+		seg.Data[k] = Unescape(v)
+	}
+	return
+}
+
+// 整理消息：合并相邻的文本消息段、去除At消息段两侧的空白、删除空的文本消息段。返回新的消息
+func (m Message) Normalize() Message {
+	merged := m.mergeText()
+	ret := make(Message, 0, len(merged))
+	for i, seg := range merged {
+		if !seg.IsText() {
+			ret = append(ret, seg)
+			continue
+		}
+		text := fmt.Sprint(seg.Data["text"])
+		if i > 0 && merged[i-1].Type == "at" {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		}
+		if i < len(merged)-1 && merged[i+1].Type == "at" {
+			text = strings.TrimRightFunc(text, unicode.IsSpace)
+		}
+		if text != "" {
+			ret = append(ret, MsgFactory.Text(text))
+		}
+	}
+	return ret
+}
+
+// 合并相邻的文本消息段，并删除空的文本消息段
+func (m Message) mergeText() Message {
+	ret := make(Message, 0, len(m))
+	for _, seg := range m {
+		if !seg.IsText() {
+			ret = append(ret, seg)
+			continue
+		}
+		text := fmt.Sprint(seg.Data["text"])
+		if text == "" {
+			continue
+		}
+		if n := len(ret); n > 0 && ret[n-1].IsText() {
+			ret[n-1] = MsgFactory.Text(ret[n-1].Data["text"].(string) + text)
+		} else {
+			ret = append(ret, MsgFactory.Text(text))
+		}
+	}
+	return ret
+}
+
+// 两条消息内容是否相同。文本的分段方式、参数值的类型（如数字1与字符串"1"）不影响比较结果
+func (m Message) Equal(other Message) bool {
+	a, b := m.mergeText(), other.mergeText()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// 消息中是否包含某种类型的消息段
+func (m Message) Contains(segmentType string) bool {
+	for _, seg := range m {
+		if seg.Type == segmentType {
+			return true
+		}
+	}
+	return false
+}
+
+// 将每个消息段替换为f的返回值，返回nil或空消息表示删除该消息段。返回新的消息
+func (m Message) Replace(f func(seg MessageSegment) Message) Message {
+	ret := make(Message, 0, len(m))
+	for _, seg := range m {
+		ret = append(ret, f(seg)...)
+	}
+	return ret
+}
+
+// 去除消息开头与结尾的空白。返回新的消息
+func (m Message) TrimSpace() Message {
+	ret := m.mergeText()
+	if n := len(ret); n > 0 && ret[n-1].IsText() {
+		ret[n-1] = MsgFactory.Text(strings.TrimRightFunc(ret[n-1].Data["text"].(string), unicode.IsSpace))
+	}
+	if len(ret) > 0 && ret[0].IsText() {
+		ret[0] = MsgFactory.Text(strings.TrimLeftFunc(ret[0].Data["text"].(string), unicode.IsSpace))
+	}
+	return ret.mergeText()
+}
+
+// 去除消息开头At qq的消息段，开头的回复消息段会被保留。返回新的消息，以及是否进行了去除
+func (m Message) StripLeadingAt(qq int64) (Message, bool) {
+	for i, seg := range m {
+		if seg.Type == "reply" {
+			continue
+		}
+		if seg.IsText() && strings.TrimSpace(fmt.Sprint(seg.Data["text"])) == "" {
+			continue
+		}
+		if seg.Type != "at" || decodeAtSegment(seg.Data).(AtSegment).QQ != qq {
+			return m, false
+		}
+		ret := append(Message{}, m[:i]...)
+		rest := Message(m[i+1:]).TrimSpace()
+		return append(ret, rest...).mergeText(), true
+	}
+	return m, false
+}
+
+// 消息中纯文本的字数（不去除空白）
+func (m Message) TextLen() int {
+	n := 0
+	for _, seg := range m {
+		if seg.IsText() {
+			n += utf8.RuneCountInString(fmt.Sprint(seg.Data["text"]))
+		}
+	}
+	return n
+}
+
+// 按纯文本的偏移截取消息，偏移以字为单位，只计算文本消息段（不去除空白），范围为[start, end)。
+//
+// end小于0表示截取到末尾。非文本消息段位于范围内时保留；截取到末尾时，末尾的非文本消息段也会保留。
+func (m Message) SliceText(start, end int) Message {
+	total := m.TextLen()
+	if end < 0 || end > total {
+		end = total
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	ret := Message{}
+	pos := 0
+	for _, seg := range m {
+		if !seg.IsText() {
+			if pos >= start && (pos < end || end == total) {
+				ret = append(ret, seg)
+			}
+			continue
+		}
+
+		runes := []rune(fmt.Sprint(seg.Data["text"]))
+		from, to := start-pos, end-pos
+		if from < 0 {
+			from = 0
+		}
+		if to > len(runes) {
+			to = len(runes)
+		}
+		if from < to {
+			ret = append(ret, MsgFactory.Text(string(runes[from:to])))
+		}
+		pos += len(runes)
+	}
+	return ret.mergeText()
+}
@@ -25,7 +25,8 @@ type MockServer struct {
 	sendEventTimeout time.Duration // 发送事件超时时间，默认1秒
 	eventRecievers   []chan<- gonebot.I_Event
 
-	messageHistory MessageHistory // 所有消息记录
+	messageHistory  MessageHistory             // 所有消息记录
+	forwardMessages map[string]gonebot.Message // 合并转发消息的内容，键为forward消息段中的ID
 }
 
 type NewMockServerOptions struct {
@@ -84,6 +85,16 @@ func (server *MockServer) SendEvent(event gonebot.I_Event) {
 	}
 }
 
+// 保存一条合并转发消息，返回可放入forward消息段的ID。nodes为由node消息段组成的消息
+func (server *MockServer) AddForwardMsg(nodes gonebot.Message) string {
+	if server.forwardMessages == nil {
+		server.forwardMessages = make(map[string]gonebot.Message)
+	}
+	id := fmt.Sprintf("forward-%d", server.getMsgId())
+	server.forwardMessages[id] = nodes
+	return id
+}
+
 // 模拟一个生命周期的Connected事件
 func (server *MockServer) ConnectedEvent() gonebot.LifeCycleMetaEvent {
 	ev := gonebot.LifeCycleMetaEvent{
@@ -107,7 +118,7 @@ func (server *MockServer) HandleRequest(action string, params gjson.Result) (int
 	case "send_private_msg":
 		userId := params.Get("user_id").Int()
 		groupId := params.Get("group_id").Int()
-		message := gonebot.ConvertJsonArrayToMessage(params.Get("message").Array())
+		message := gonebot.ConvertJsonToMessage(params.Get("message"))
 		msgId := server.getMsgId()
 		server.addMyMessageToMessageHistory(msgId, message, userId, 0)
 		if groupId == 0 {
@@ -119,7 +130,7 @@ func (server *MockServer) HandleRequest(action string, params gjson.Result) (int
 
 	case "send_group_msg":
 		groupId := params.Get("group_id").Int()
-		message := gonebot.ConvertJsonArrayToMessage(params.Get("message").Array())
+		message := gonebot.ConvertJsonToMessage(params.Get("message"))
 		msgId := server.getMsgId()
 		server.addMyMessageToMessageHistory(msgId, message, 0, groupId)
 		logrus.Infof("发送群聊消息到%d：%s", groupId, message.String())
@@ -128,13 +139,30 @@ func (server *MockServer) HandleRequest(action string, params gjson.Result) (int
 	case "send_msg":
 		userId := params.Get("user_id").Int()
 		groupId := params.Get("group_id").Int()
-		message := gonebot.ConvertJsonArrayToMessage(params.Get("message").Array())
+		message := gonebot.ConvertJsonToMessage(params.Get("message"))
 		msgId := server.getMsgId()
 		server.addMyMessageToMessageHistory(msgId, message, userId, groupId)
 		return resp{"message_id": msgId}, nil
 
-	case "send_group_forward_msg":
-		return nil, nil
+	case "send_group_forward_msg", "send_private_forward_msg":
+		userId := params.Get("user_id").Int()
+		groupId := params.Get("group_id").Int()
+		nodes := gonebot.ConvertJsonArrayToMessage(params.Get("messages").Array())
+		msgId := server.getMsgId()
+		server.addMyMessageToMessageHistory(msgId, nodes, userId, groupId)
+		logrus.Infof("发送合并转发消息，共%d个节点", len(nodes))
+		return resp{"message_id": msgId}, nil
+	case "get_forward_msg":
+		id := params.Get("message_id").String()
+		if id == "" {
+			id = params.Get("id").String()
+		}
+		nodes, ok := server.forwardMessages[id]
+		if !ok {
+			return nil, fmt.Errorf("合并转发消息%s不存在", id)
+		}
+		return resp{"message": nodes}, nil
+
 	case "delete_msg":
 		msgId := params.Get("message_id").Int()
 		logrus.Infof("撤回消息%d", msgId)
@@ -180,8 +208,7 @@ func (server *MockServer) HandleRequest(action string, params gjson.Result) (int
 		opParams := params.Get("operation")
 		switch {
 		case opParams.Get("reply").Exists():
-			reply := opParams.Get("reply").Array()
-			msg := gonebot.ConvertJsonArrayToMessage(reply)
+			msg := gonebot.ConvertJsonToMessage(opParams.Get("reply"))
 			msgId := server.getMsgId()
 			ev := gonebot.ConvertJsonObjectToEvent(params.Get("context"))
 			switch ev := ev.(type) {
@@ -260,6 +287,17 @@ func (server *MockServer) NewGroupSession(groupId int64) *GroupSession {
 	}
 }
 
+// 创建频道会话。机器人在频道中的ID默认与QQ号相同
+func (server *MockServer) NewGuildSession(guildId, channelId string) *GuildSession {
+	return &GuildSession{
+		Server:     server,
+		BotId:      server.BotId,
+		SelfTinyId: fmt.Sprintf("%d", server.BotId),
+		GuildId:    guildId,
+		ChannelId:  channelId,
+	}
+}
+
 // 将Event转为消息记录
 func (server *MockServer) addEventToMessageHistory(event gonebot.I_Event) {
 	var rcd MessageRecord
@@ -66,6 +66,42 @@ func (s *PrivateSession) MessageEventByText(txt string) gonebot.PrivateMessageEv
 	return s.MessageEvent(gonebot.MsgPrint(txt))
 }
 
+// 模拟一个机器人自己发送私聊消息的事件（message_sent）
+func (s *PrivateSession) MessageSentEvent(msg gonebot.Message) gonebot.PrivateMessageSentEvent {
+	ev := gonebot.PrivateMessageSentEvent{
+		PrivateMessageEvent: gonebot.PrivateMessageEvent{
+			MessageEvent: gonebot.MessageEvent{
+				Event: gonebot.Event{
+					Time:      time.Now().Unix(),
+					SelfId:    s.BotId,
+					PostType:  gonebot.PostType_MessageSentEvent,
+					EventName: "message_sent.private.friend",
+					ToMe:      false,
+				},
+				MessageType: "private",
+				SubType:     "friend",
+				MessageId:   s.getMsgId(),
+				UserId:      s.BotId,
+				Message:     msg,
+				RawMessage:  msg.String(),
+			},
+			Sender: &gonebot.MessageEventSender{
+				UserId:   s.BotId,
+				Nickname: s.Server.BotName,
+			},
+		},
+		TargetId: s.UserId,
+	}
+	s.Server.SendEvent(&ev)
+	return ev
+}
+
+// 模拟一个私聊合并转发消息事件，nodes为由node消息段组成的消息，可通过get_forward_msg获取
+func (s *PrivateSession) ForwardMessageEvent(nodes gonebot.Message) gonebot.PrivateMessageEvent {
+	id := s.Server.AddForwardMsg(nodes)
+	return s.MessageEvent(gonebot.Message{gonebot.MsgFactory.Forward(id)})
+}
+
 // 模拟撤回消息事件
 func (s *PrivateSession) RecallEvent(msgId int32) gonebot.FriendRecallNoticeEvent {
 	ev := gonebot.FriendRecallNoticeEvent{
@@ -175,6 +211,89 @@ func (s *GroupSession) MessageEvent(userId int64, msg gonebot.Message) gonebot.G
 	return ev
 }
 
+// 模拟一个群聊合并转发消息事件，nodes为由node消息段组成的消息，可通过get_forward_msg获取
+func (s *GroupSession) ForwardMessageEvent(userId int64, nodes gonebot.Message) gonebot.GroupMessageEvent {
+	id := s.Server.AddForwardMsg(nodes)
+	return s.MessageEvent(userId, gonebot.Message{gonebot.MsgFactory.Forward(id)})
+}
+
+// 模拟一个机器人自己发送群聊消息的事件（message_sent）
+func (s *GroupSession) MessageSentEvent(msg gonebot.Message) gonebot.GroupMessageSentEvent {
+	ev := gonebot.GroupMessageSentEvent{
+		GroupMessageEvent: gonebot.GroupMessageEvent{
+			MessageEvent: gonebot.MessageEvent{
+				Event: gonebot.Event{
+					Time:      time.Now().Unix(),
+					SelfId:    s.BotId,
+					PostType:  gonebot.PostType_MessageSentEvent,
+					EventName: "message_sent.group.normal",
+					ToMe:      false,
+				},
+				MessageType: "group",
+				SubType:     "normal",
+				MessageId:   s.getMsgId(),
+				UserId:      s.BotId,
+				Message:     msg,
+				RawMessage:  msg.String(),
+			},
+			GroupId: s.GroupId,
+			Sender: &gonebot.GroupMessageEventSender{
+				MessageEventSender: gonebot.MessageEventSender{
+					UserId:   s.BotId,
+					Nickname: s.Server.BotName,
+				},
+				Role: "member",
+			},
+		},
+	}
+	s.Server.SendEvent(&ev)
+	return ev
+}
+
+// 模拟一个群消息表情回应事件
+func (s *GroupSession) EmojiLikeEvent(userId int64, msgId int32, emojiId string) gonebot.GroupMsgEmojiLikeNoticeEvent {
+	ev := gonebot.GroupMsgEmojiLikeNoticeEvent{
+		NoticeEvent: gonebot.NoticeEvent{
+			Event: gonebot.Event{
+				Time:      time.Now().Unix(),
+				SelfId:    s.BotId,
+				PostType:  gonebot.PostType_NoticeEvent,
+				EventName: gonebot.EventName_GroupMsgEmojiLike,
+				ToMe:      userId == s.BotId,
+			},
+			NoticeType: "group_msg_emoji_like",
+		},
+		GroupId:   s.GroupId,
+		UserId:    userId,
+		MessageId: msgId,
+		Likes:     []gonebot.EmojiLike{{EmojiId: emojiId, Count: 1}},
+	}
+	s.Server.SendEvent(&ev)
+	return ev
+}
+
+// 模拟一个群成员获得头衔的事件
+func (s *GroupSession) TitleEvent(userId int64, title string) gonebot.TitleNoticeEvent {
+	ev := gonebot.TitleNoticeEvent{
+		NoticeEvent: gonebot.NoticeEvent{
+			Event: gonebot.Event{
+				Time:      time.Now().Unix(),
+				SelfId:    s.BotId,
+				PostType:  gonebot.PostType_NoticeEvent,
+				EventName: gonebot.EventName_NotifyTitle,
+				ToMe:      userId == s.BotId,
+			},
+			NoticeType: "notify",
+		},
+		SubType: "title",
+		GroupId: s.GroupId,
+		UserId:  userId,
+		Title:   title,
+	}
+	s.Server.SendEvent(&ev)
+	return ev
+}
+
 // 模拟一个群聊匿名消息事件
 func (s *GroupSession) AnonymousMessageEvent(anonymous gonebot.Anonymous, msg gonebot.Message) gonebot.GroupMessageEvent {
 	ev := gonebot.GroupMessageEvent{
@@ -201,3 +320,68 @@ func (s *GroupSession) AnonymousMessageEvent(anonymous gonebot.Anonymous, msg go
 	s.Server.SendEvent(&ev)
 	return ev
 }
+
+// 频道会话
+type GuildSession struct {
+	Server     *MockServer
+	BotId      int64  // 机器人QQ号
+	SelfTinyId string // 机器人在频道中的ID
+
+	GuildId   string // 频道ID
+	ChannelId string // 子频道ID
+}
+
+// 模拟一个频道消息事件。userId为发送者的频道用户ID
+func (s *GuildSession) MessageEvent(userId string, nickname string, msg gonebot.Message) gonebot.GuildMessageEvent {
+	ev := gonebot.GuildMessageEvent{
+		Event: gonebot.Event{
+			Time:      time.Now().Unix(),
+			SelfId:    s.BotId,
+			PostType:  gonebot.PostType_MessageEvent,
+			EventName: "message.guild.channel",
+		},
+		MessageType: "guild",
+		SubType:     "channel",
+		GuildId:     s.GuildId,
+		ChannelId:   s.ChannelId,
+		UserId:      userId,
+		MessageId:   fmt.Sprintf("%d", s.Server.getMsgId()),
+		Message:     msg,
+		SelfTinyId:  s.SelfTinyId,
+		Sender: &gonebot.GuildMessageEventSender{
+			TinyId:   userId,
+			Nickname: nickname,
+		},
+	}
+	for _, qq := range msg.AtTargets() {
+		if fmt.Sprintf("%d", qq) == s.SelfTinyId {
+			ev.ToMe = true
+		}
+	}
+	s.Server.SendEvent(&ev)
+	return ev
+}
+
+// 模拟一个频道消息表情贴更新事件
+func (s *GuildSession) ReactionsUpdatedEvent(userId string, messageId string, emojiId string, count int32) gonebot.GuildMessageReactionsUpdatedNoticeEvent {
+	ev := gonebot.GuildMessageReactionsUpdatedNoticeEvent{
+		GuildNoticeEvent: gonebot.GuildNoticeEvent{
+			NoticeEvent: gonebot.NoticeEvent{
+				Event: gonebot.Event{
+					Time:      time.Now().Unix(),
+					SelfId:    s.BotId,
+					PostType:  gonebot.PostType_NoticeEvent,
+					EventName: gonebot.EventName_GuildMessageReactionsUpdated,
+				},
+				NoticeType: "message_reactions_updated",
+			},
+			GuildId:   s.GuildId,
+			ChannelId: s.ChannelId,
+			UserId:    userId,
+		},
+		MessageId:        messageId,
+		CurrentReactions: []gonebot.GuildReaction{{EmojiId: emojiId, Count: count}},
+	}
+	s.Server.SendEvent(&ev)
+	return ev
+}
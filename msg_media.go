@@ -0,0 +1,274 @@
+package gonebot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 发送二进制媒体文件时的设置，从provider_config中当前Provider的配置读取
+type mediaOptions struct {
+	// Provider是否与OneBot实现共享文件系统。共享时本地文件以file:///发送，否则读取后以base64://发送
+	SharedFilesystem bool
+	// 媒体文件的大小上限，单位字节
+	MaxSize int64
+}
+
+const defaultMediaMaxSize = 30 << 20
+
+var defaultMediaOptions = mediaOptions{MaxSize: defaultMediaMaxSize}
+
+// 读取provider_config中的shared_filesystem、media_max_size（单位MB）
+func mediaOptionsFromConfig(cfg Config) mediaOptions {
+	opt := defaultMediaOptions
+	if cfg == nil {
+		return opt
+	}
+	base := cfg.GetBaseConfig()
+	mp := base.ProviderConfig[base.Provider]
+	if v, ok := mp["shared_filesystem"].(bool); ok {
+		opt.SharedFilesystem = v
+	}
+	if v := (msgSegData{"v": mp["media_max_size"]}).getInt64("v"); v > 0 {
+		opt.MaxSize = v << 20
+	}
+	return opt
+}
+
+// 按当前配置构造消息段的工厂。与MsgFactory的区别在于，ImageFromFile等方法会使用
+// 配置中的shared_filesystem与media_max_size，且配置文件重新载入后立即生效
+func (bot *Bot) MsgFactory() messageSegmentFactory {
+	opt := mediaOptionsFromConfig(bot.config.load())
+	return messageSegmentFactory{media: &opt}
+}
+
+func (f messageSegmentFactory) mediaOptions() mediaOptions {
+	if f.media == nil {
+		return defaultMediaOptions
+	}
+	return *f.media
+}
+
+// 媒体文件的类别，与消息段类型一致
+const (
+	mediaKind_Image  = "image"
+	mediaKind_Record = "record"
+	mediaKind_Video  = "video"
+)
+
+// 根据文件头识别媒体格式，返回类别与格式名。无法识别时返回空字符串
+func detectMediaFormat(data []byte) (kind string, format string) {
+	hasPrefix := func(prefix string) bool {
+		return bytes.HasPrefix(data, []byte(prefix))
+	}
+	riffType := func() string {
+		if len(data) >= 12 && hasPrefix("RIFF") {
+			return string(data[8:12])
+		}
+		return ""
+	}
+
+	switch {
+	case hasPrefix("\xFF\xD8\xFF"):
+		return mediaKind_Image, "jpeg"
+	case hasPrefix("\x89PNG\r\n\x1a\n"):
+		return mediaKind_Image, "png"
+	case hasPrefix("GIF87a"), hasPrefix("GIF89a"):
+		return mediaKind_Image, "gif"
+	case riffType() == "WEBP":
+		return mediaKind_Image, "webp"
+	case hasPrefix("BM"):
+		return mediaKind_Image, "bmp"
+
+	case hasPrefix("#!AMR"):
+		return mediaKind_Record, "amr"
+	case hasPrefix("#!SILK"), hasPrefix("\x02#!SILK"):
+		return mediaKind_Record, "silk"
+	case hasPrefix("ID3"), len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return mediaKind_Record, "mp3"
+	case riffType() == "WAVE":
+		return mediaKind_Record, "wav"
+	case hasPrefix("OggS"):
+		return mediaKind_Record, "ogg"
+	case hasPrefix("fLaC"):
+		return mediaKind_Record, "flac"
+
+	case riffType() == "AVI ":
+		return mediaKind_Video, "avi"
+	case hasPrefix("FLV"):
+		return mediaKind_Video, "flv"
+	case hasPrefix("\x1A\x45\xDF\xA3"):
+		return mediaKind_Video, "mkv"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		// MP4家族，按品牌区分音频与视频
+		switch brand := string(data[8:12]); brand {
+		case "M4A ", "M4B ":
+			return mediaKind_Record, "m4a"
+		case "qt  ":
+			return mediaKind_Video, "mov"
+		default:
+			return mediaKind_Video, "mp4"
+		}
+	}
+	return "", ""
+}
+
+// 检查数据的格式与大小，通过后编码为base64://
+func encodeMediaBytes(opt mediaOptions, kind string, data []byte) (string, error) {
+	if max := opt.MaxSize; int64(len(data)) > max {
+		return "", fmt.Errorf("%w: %d字节，上限为%d字节", ErrMediaTooLarge, len(data), max)
+	}
+	if k, format := detectMediaFormat(data); k != kind {
+		if format == "" {
+			format = "未知格式"
+		}
+		return "", fmt.Errorf("%w: 需要%s，实际为%s", ErrUnsupportedMediaFormat, kind, format)
+	}
+	return "base64://" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// 从Reader中读取媒体文件，超出大小上限时不会读完全部数据
+func encodeMediaReader(opt mediaOptions, kind string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, opt.MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	return encodeMediaBytes(opt, kind, data)
+}
+
+// 读取本地媒体文件。共享文件系统时只检查文件头，以file:///发送
+func encodeMediaFile(opt mediaOptions, kind string, path string) (string, error) {
+	if !opt.SharedFilesystem {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return encodeMediaReader(opt, kind, f)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if stat.Size() > opt.MaxSize {
+		return "", fmt.Errorf("%w: %d字节，上限为%d字节", ErrMediaTooLarge, stat.Size(), opt.MaxSize)
+	}
+	header := make([]byte, 16)
+	n, _ := io.ReadFull(f, header)
+	if k, format := detectMediaFormat(header[:n]); k != kind {
+		if format == "" {
+			format = "未知格式"
+		}
+		return "", fmt.Errorf("%w: 需要%s，实际为%s", ErrUnsupportedMediaFormat, kind, format)
+	}
+	return fileURI(abs), nil
+}
+
+// 将绝对路径转换为file:///形式的URI
+func fileURI(abs string) string {
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		// Windows盘符
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// 由二进制数据构造图片，支持jpeg、png、gif、webp、bmp。超过大小上限时返回错误，
+// 全局的MsgFactory使用默认的上限，bot.MsgFactory()使用配置中的media_max_size
+func (f messageSegmentFactory) ImageFromBytes(data []byte, optional *imageOptions) (MessageSegment, error) {
+	file, err := encodeMediaBytes(f.mediaOptions(), mediaKind_Image, data)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Image(file, optional), nil
+}
+
+// 由Reader构造图片，大小上限同ImageFromBytes
+func (f messageSegmentFactory) ImageFromReader(r io.Reader, optional *imageOptions) (MessageSegment, error) {
+	file, err := encodeMediaReader(f.mediaOptions(), mediaKind_Image, r)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Image(file, optional), nil
+}
+
+// 由本地文件构造图片。bot.MsgFactory()在配置开启shared_filesystem时直接以file:///发送路径，
+// 全局的MsgFactory不读取配置，总是读取文件后以base64发送
+func (f messageSegmentFactory) ImageFromFile(path string, optional *imageOptions) (MessageSegment, error) {
+	file, err := encodeMediaFile(f.mediaOptions(), mediaKind_Image, path)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Image(file, optional), nil
+}
+
+// 由二进制数据构造语音，支持amr、silk、mp3、wav、ogg、flac、m4a。大小上限同ImageFromBytes
+func (f messageSegmentFactory) RecordFromBytes(data []byte, optional *recordOptions) (MessageSegment, error) {
+	file, err := encodeMediaBytes(f.mediaOptions(), mediaKind_Record, data)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Record(file, optional), nil
+}
+
+// 由Reader构造语音，大小上限同ImageFromBytes
+func (f messageSegmentFactory) RecordFromReader(r io.Reader, optional *recordOptions) (MessageSegment, error) {
+	file, err := encodeMediaReader(f.mediaOptions(), mediaKind_Record, r)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Record(file, optional), nil
+}
+
+// 由本地文件构造语音，是否以file:///发送同ImageFromFile
+func (f messageSegmentFactory) RecordFromFile(path string, optional *recordOptions) (MessageSegment, error) {
+	file, err := encodeMediaFile(f.mediaOptions(), mediaKind_Record, path)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Record(file, optional), nil
+}
+
+// 由二进制数据构造视频，支持mp4、mov、avi、flv、mkv。大小上限同ImageFromBytes
+func (f messageSegmentFactory) VideoFromBytes(data []byte, optional *videoOptions) (MessageSegment, error) {
+	file, err := encodeMediaBytes(f.mediaOptions(), mediaKind_Video, data)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Video(file, optional), nil
+}
+
+// 由Reader构造视频，大小上限同ImageFromBytes
+func (f messageSegmentFactory) VideoFromReader(r io.Reader, optional *videoOptions) (MessageSegment, error) {
+	file, err := encodeMediaReader(f.mediaOptions(), mediaKind_Video, r)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Video(file, optional), nil
+}
+
+// 由本地文件构造视频，是否以file:///发送同ImageFromFile
+func (f messageSegmentFactory) VideoFromFile(path string, optional *videoOptions) (MessageSegment, error) {
+	file, err := encodeMediaFile(f.mediaOptions(), mediaKind_Video, path)
+	if err != nil {
+		return MessageSegment{}, err
+	}
+	return f.Video(file, optional), nil
+}
@@ -1,7 +1,10 @@
 package gonebot
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -17,26 +20,64 @@ func (seg *MessageSegment) IsText() bool {
 	return seg.Type == "text"
 }
 
+// 转换为CQ码，参数按键名排序，保证结果稳定
 func (seg MessageSegment) String() string {
 	if seg.IsText() {
-		return Escape(seg.Data["text"].(string), false)
+		return Escape(fmt.Sprint(seg.Data["text"]), false)
 	}
 
 	if len(seg.Data) == 0 {
 		return fmt.Sprintf("[CQ:%s]", seg.Type)
 	}
 
+	keys := make([]string, 0, len(seg.Data))
+	for k := range seg.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	params := make([]string, 0, len(seg.Data))
-	for k, v := range seg.Data {
-		vStr := fmt.Sprintf("%v", v)
-		params = append(params, fmt.Sprintf("%s=%s", k, Escape(vStr, false)))
+	for _, k := range keys {
+		vStr := fmt.Sprintf("%v", seg.Data[k])
+		params = append(params, fmt.Sprintf("%s=%s", k, Escape(vStr, true)))
 	}
 	return fmt.Sprintf("[CQ:%s,%s]", seg.Type, strings.Join(params, ","))
 
 }
 
-type messageSegmentFactory struct{}
+// 序列化为OneBot的消息段对象，data中的值保持原有类型，data为空时输出{}
+func (seg MessageSegment) MarshalJSON() ([]byte, error) {
+	data := seg.Data
+	if data == nil {
+		data = msgSegData{}
+	}
+	return json.Marshal(struct {
+		Type string     `json:"type"`
+		Data msgSegData `json:"data"`
+	}{seg.Type, data})
+}
+
+// 反序列化OneBot的消息段对象，data中的数字解析为json.Number，避免精度丢失，也避免转为CQ码时变成科学计数法
+func (seg *MessageSegment) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Type string     `json:"type"`
+		Data msgSegData `json:"data"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	seg.Type = raw.Type
+	seg.Data = raw.Data
+	return nil
+}
+
+type messageSegmentFactory struct {
+	media *mediaOptions // ImageFromFile等方法使用的设置，为nil时使用默认设置
+}
 
+// 消息段工厂。ImageFromFile等方法使用默认设置，需要使用配置中的设置时请用bot.MsgFactory()
 var MsgFactory = messageSegmentFactory{}
 
 // 纯文本
@@ -90,6 +131,18 @@ func (p *imageOptions) SetTimeout(t int) *imageOptions {
 	return p
 }
 
+// go-cqhttp扩展。图片子类型，用于区分普通图片与表情包等，0为普通图片
+func (p *imageOptions) SetSubType(subType int) *imageOptions {
+	(*p)["subType"] = strconv.Itoa(subType)
+	return p
+}
+
+// go-cqhttp扩展。秀图特效ID，仅在type为"show"时有效
+func (p *imageOptions) SetId(id int) *imageOptions {
+	(*p)["id"] = strconv.Itoa(id)
+	return p
+}
+
 // 图片。file可以为网络URL、本地URI、Base64
 func (f messageSegmentFactory) Image(file string, optional *imageOptions) MessageSegment {
 	if optional == nil {
@@ -103,6 +156,26 @@ func (f messageSegmentFactory) Image(file string, optional *imageOptions) Messag
 	}
 }
 
+// 闪照
+func (f messageSegmentFactory) FlashImage(file string) MessageSegment {
+	return f.Image(file, f.ImageOptions().SetType("flash"))
+}
+
+// go-cqhttp扩展。秀图特效ID
+const (
+	ShowImageEffect_Normal   = 40000 // 普通
+	ShowImageEffect_Phantom  = 40001 // 幻影
+	ShowImageEffect_Shake    = 40002 // 抖动
+	ShowImageEffect_Birthday = 40003 // 生日
+	ShowImageEffect_Love     = 40004 // 爱你
+	ShowImageEffect_Friend   = 40005 // 征友
+)
+
+// go-cqhttp扩展。秀图，effectId为ShowImageEffect_*
+func (f messageSegmentFactory) ShowImage(file string, effectId int) MessageSegment {
+	return f.Image(file, f.ImageOptions().SetType("show").SetId(effectId))
+}
+
 type recordOptions msgSegData
 
 // 语音的可选参数
@@ -207,6 +280,13 @@ func (f messageSegmentFactory) AtAll() MessageSegment {
 	}
 }
 
+// go-cqhttp扩展。群聊At指定QQ，当QQ号不在群内时显示name
+func (f messageSegmentFactory) AtWithName(qq int64, name string) MessageSegment {
+	seg := f.AtSomeone(qq)
+	seg.Data["name"] = name
+	return seg
+}
+
 // 猜拳魔法表情
 func (f messageSegmentFactory) Rps() MessageSegment {
 	return MessageSegment{
@@ -452,3 +532,93 @@ func (f messageSegmentFactory) TTS(text string) MessageSegment {
 		},
 	}
 }
+
+type cardImageOptions msgSegData
+
+// 装逼大图的可选参数
+func (f messageSegmentFactory) CardImageOptions() *cardImageOptions {
+	return &cardImageOptions{}
+}
+
+// 最小宽度，默认400
+func (p *cardImageOptions) SetMinWidth(w int) *cardImageOptions {
+	(*p)["minwidth"] = strconv.Itoa(w)
+	return p
+}
+
+// 最小高度，默认400
+func (p *cardImageOptions) SetMinHeight(h int) *cardImageOptions {
+	(*p)["minheight"] = strconv.Itoa(h)
+	return p
+}
+
+// 最大宽度，默认500
+func (p *cardImageOptions) SetMaxWidth(w int) *cardImageOptions {
+	(*p)["maxwidth"] = strconv.Itoa(w)
+	return p
+}
+
+// 最大高度，默认1000
+func (p *cardImageOptions) SetMaxHeight(h int) *cardImageOptions {
+	(*p)["maxheight"] = strconv.Itoa(h)
+	return p
+}
+
+// 分享来源的名称
+func (p *cardImageOptions) SetSource(source string) *cardImageOptions {
+	(*p)["source"] = source
+	return p
+}
+
+// 分享来源的图标URL
+func (p *cardImageOptions) SetIcon(icon string) *cardImageOptions {
+	(*p)["icon"] = icon
+	return p
+}
+
+// go-cqhttp扩展。装逼大图，file同Image
+func (f messageSegmentFactory) CardImage(file string, optional *cardImageOptions) MessageSegment {
+	if optional == nil {
+		optional = f.CardImageOptions()
+	}
+	data := msgSegData{}
+	for k, v := range *optional {
+		data[k] = v
+	}
+	data["file"] = file
+	return MessageSegment{
+		Type: "cardimage",
+		Data: data,
+	}
+}
+
+// go-cqhttp扩展。礼物，仅群聊可用。id为礼物ID，范围0~13
+func (f messageSegmentFactory) Gift(qq int64, id int) MessageSegment {
+	return MessageSegment{
+		Type: "gift",
+		Data: msgSegData{
+			"qq": strconv.FormatInt(qq, 10),
+			"id": strconv.Itoa(id),
+		},
+	}
+}
+
+// go-cqhttp扩展。红包，仅能接收，用于构造测试消息
+func (f messageSegmentFactory) Redbag(title string) MessageSegment {
+	return MessageSegment{
+		Type: "redbag",
+		Data: msgSegData{
+			"title": title,
+		},
+	}
+}
+
+// go-cqhttp扩展。合并转发，仅能接收，id可用于Bot.GetForwardMsgById获取内容。用于构造测试消息
+func (f messageSegmentFactory) Forward(id string) MessageSegment {
+	return MessageSegment{
+		Type: "forward",
+		Data: msgSegData{
+			"id": id,
+		},
+	}
+}
@@ -0,0 +1,335 @@
+package gonebot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// 消息模板，语法同text/template，另外支持“{name}”形式的具名占位符，等价于“{{.name}}”。例如：
+//
+//	欢迎{user}加入本群！{{if .admin}}你是管理员。{{end}}
+//	{{range .items}}- {.}
+//	{{end}}
+//
+// 数据中的MessageSegment、Message、TypedSegment会作为消息段插入，而非转换成文本。
+// 模板中还可以使用at、face、image、reply函数直接生成消息段，如“{{at .uid}}”。
+//
+// 可以直接作为插件配置结构体的字段，从配置文件中载入模板。
+type MsgTemplate struct {
+	src  string
+	tmpl *template.Template
+}
+
+// 解析消息模板
+func ParseMsgTemplate(src string) (*MsgTemplate, error) {
+	t := &MsgTemplate{}
+	if err := t.parse(src); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 作用同ParseMsgTemplate，解析失败时会panic
+func MustParseMsgTemplate(src string) *MsgTemplate {
+	t, err := ParseMsgTemplate(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *MsgTemplate) parse(src string) error {
+	tmpl, err := template.New("msg").Funcs(msgTemplateFuncs).Parse(expandNamedPlaceholders(src))
+	if err != nil {
+		return fmt.Errorf("解析消息模板失败：%w", err)
+	}
+	t.src = src
+	t.tmpl = tmpl
+	return nil
+}
+
+// 模板原文
+func (t *MsgTemplate) String() string {
+	return t.src
+}
+
+// 从文本载入模板，用于插件配置与YAML
+func (t *MsgTemplate) UnmarshalText(text []byte) error {
+	return t.parse(string(text))
+}
+
+func (t MsgTemplate) MarshalText() ([]byte, error) {
+	return []byte(t.src), nil
+}
+
+// 使用数据渲染模板，data通常为map[string]interface{}，也可以是结构体
+func (t *MsgTemplate) Render(data interface{}) (msg Message, err error) {
+	if t.tmpl == nil {
+		return Message{}, nil
+	}
+	buf := bytes.Buffer{}
+	if err = t.tmpl.Execute(&buf, prepareTemplateData(reflect.ValueOf(data))); err != nil {
+		err = fmt.Errorf("渲染消息模板失败：%w", err)
+		return
+	}
+	return decodeSegmentTokens(buf.String())
+}
+
+// 作用同Render，渲染失败时会panic
+func (t *MsgTemplate) MustRender(data interface{}) Message {
+	msg, err := t.Render(data)
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+// 将“{name}”、“{a.b}”形式的占位符展开为“{{.name}}”、“{{.a.b}}”，“{{...}}”原样保留
+func expandNamedPlaceholders(src string) string {
+	builder := strings.Builder{}
+	builder.Grow(len(src))
+	for i := 0; i < len(src); {
+		if strings.HasPrefix(src[i:], "{{") {
+			end := strings.Index(src[i:], "}}")
+			if end < 0 {
+				builder.WriteString(src[i:])
+				break
+			}
+			builder.WriteString(src[i : i+end+2])
+			i += end + 2
+			continue
+		}
+		if src[i] == '{' {
+			// 没有配对的“}”时原样保留
+			end := strings.IndexByte(src[i:], '}')
+			if end > 0 && isPlaceholderName(src[i+1:i+end]) {
+				name := src[i+1 : i+end]
+				if name != "." {
+					name = "." + name
+				}
+				builder.WriteString("{{" + name + "}}")
+				i += end + 1
+				continue
+			}
+		}
+		builder.WriteByte(src[i])
+		i++
+	}
+	return builder.String()
+}
+
+// 是否为合法的占位符名，由字母、数字、下划线组成，可用“.”访问子字段
+func isPlaceholderName(s string) bool {
+	if s == "." {
+		return true
+	}
+	for _, part := range strings.Split(s, ".") {
+		if part == "" {
+			return false
+		}
+		for i, c := range part {
+			isLetter := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c > 0x7f
+			if !isLetter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 消息段在模板输出中的标记。消息段被编码进标记中，渲染完毕后再还原
+const (
+	segmentTokenStart = "\uE000"
+	segmentTokenEnd   = "\uE001"
+)
+
+func encodeSegmentToken(msg Message) string {
+	b, _ := json.Marshal(msg)
+	return segmentTokenStart + base64.StdEncoding.EncodeToString(b) + segmentTokenEnd
+}
+
+// 将模板输出还原为消息，相邻的文本会被合并
+func decodeSegmentTokens(out string) (msg Message, err error) {
+	msg = Message{}
+	text := strings.Builder{}
+	flushText := func() {
+		if text.Len() > 0 {
+			msg.AppendText(text.String())
+			text.Reset()
+		}
+	}
+
+	for {
+		start := strings.Index(out, segmentTokenStart)
+		if start < 0 {
+			text.WriteString(out)
+			break
+		}
+		text.WriteString(out[:start])
+		out = out[start+len(segmentTokenStart):]
+
+		end := strings.Index(out, segmentTokenEnd)
+		if end < 0 {
+			return nil, fmt.Errorf("渲染消息模板失败：消息段标记不完整")
+		}
+		b, e := base64.StdEncoding.DecodeString(out[:end])
+		if e != nil {
+			return nil, fmt.Errorf("渲染消息模板失败：%w", e)
+		}
+		var segs Message
+		if e = json.Unmarshal(b, &segs); e != nil {
+			return nil, fmt.Errorf("渲染消息模板失败：%w", e)
+		}
+		for _, seg := range segs {
+			if seg.IsText() {
+				text.WriteString(fmt.Sprint(seg.Data["text"]))
+				continue
+			}
+			flushText()
+			msg.AppendSegment(seg)
+		}
+		out = out[end+len(segmentTokenEnd):]
+	}
+	flushText()
+	return
+}
+
+var (
+	messageType        = reflect.TypeOf(Message{})
+	messageSegmentType = reflect.TypeOf(MessageSegment{})
+	typedSegmentType   = reflect.TypeOf((*TypedSegment)(nil)).Elem()
+)
+
+// 将数据中的消息段替换为标记，递归处理map、slice与结构体。
+// 含有消息段的结构体会转换为以字段名为键的map，此时模板中无法再调用它的方法
+func prepareTemplateData(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == messageType:
+		return encodeSegmentToken(v.Interface().(Message))
+	case v.Type() == reflect.PtrTo(messageType) && !v.IsNil():
+		return encodeSegmentToken(*v.Interface().(*Message))
+	case v.Type() == messageSegmentType:
+		return encodeSegmentToken(Message{v.Interface().(MessageSegment)})
+	case v.Type().Implements(typedSegmentType):
+		return encodeSegmentToken(Message{v.Interface().(TypedSegment).ToSegment()})
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = prepareTemplateData(v.MapIndex(k))
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = prepareTemplateData(v.Index(i))
+		}
+		return s
+	case reflect.Pointer:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Struct && containsSegments(v.Type().Elem(), nil) {
+			return prepareTemplateData(v.Elem())
+		}
+	case reflect.Struct:
+		if containsSegments(v.Type(), nil) {
+			m := make(map[string]interface{}, v.NumField())
+			addStructFields(m, v)
+			return m
+		}
+	}
+	return v.Interface()
+}
+
+// 将结构体的导出字段加入m，嵌入结构体的字段提升到外层
+func addStructFields(m map[string]interface{}, v reflect.Value) {
+	embedded := []reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, v.Field(i))
+		}
+		if field.IsExported() {
+			m[field.Name] = prepareTemplateData(v.Field(i))
+		}
+	}
+	// 外层的同名字段优先
+	for _, e := range embedded {
+		inner := map[string]interface{}{}
+		addStructFields(inner, e)
+		for k, val := range inner {
+			if _, ok := m[k]; !ok {
+				m[k] = val
+			}
+		}
+	}
+}
+
+// 类型中是否可能含有消息段。interface{}可能装着消息段，也视为含有
+func containsSegments(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if t == messageType || t == messageSegmentType || t.Implements(typedSegmentType) {
+		return true
+	}
+	if visited[t] {
+		return false
+	}
+	if visited == nil {
+		visited = map[reflect.Type]bool{}
+	}
+	visited[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return containsSegments(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); (f.IsExported() || f.Anonymous) && containsSegments(f.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 模板中可用的函数，用于直接生成消息段
+var msgTemplateFuncs = template.FuncMap{
+	// At某人，参数为QQ号或"all"
+	"at": func(qq interface{}) string {
+		if fmt.Sprint(qq) == "all" {
+			return encodeSegmentToken(Message{MsgFactory.AtAll()})
+		}
+		return encodeSegmentToken(Message{MsgFactory.AtSomeone(msgSegData{"qq": qq}.getInt64("qq"))})
+	},
+	// QQ表情
+	"face": func(id interface{}) string {
+		return encodeSegmentToken(Message{MsgFactory.Face(int(msgSegData{"id": id}.getInt64("id")))})
+	},
+	// 图片，参数为网络URL、本地URI或Base64
+	"image": func(file string) string {
+		return encodeSegmentToken(Message{MsgFactory.Image(file, nil)})
+	},
+	// 回复某条消息
+	"reply": func(id interface{}) string {
+		return encodeSegmentToken(Message{MsgFactory.Reply(msgSegData{"id": id}.getInt64("id"))})
+	},
+}
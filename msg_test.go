@@ -1,8 +1,17 @@
 package gonebot
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/tidwall/gjson"
 )
 
 func Test_Image(t *testing.T) {
@@ -105,7 +114,7 @@ func Test_String(t *testing.T) {
 					MsgFactory.ImageOptions().SetCache(false).SetProxy(true)),
 				MsgFactory.Text("hello"),
 			},
-			"[CQ:image,cache=0,proxy=1,file=http://www.baidu.com/img/bd_logo1.png]hello",
+			"[CQ:image,cache=0,file=http://www.baidu.com/img/bd_logo1.png,proxy=1]hello",
 		},
 		{
 			Message{MsgFactory.Shake()},
@@ -119,3 +128,411 @@ func Test_String(t *testing.T) {
 		}
 	}
 }
+
+func Test_Split(t *testing.T) {
+	testData := []struct {
+		msg         Message
+		maxChars    int
+		maxSegments int
+		expect      []string
+	}{
+		{
+			MsgPrint("short"),
+			10, 10,
+			[]string{"short"},
+		},
+		{
+			MsgPrint("line1\nline2\nline3"),
+			12, 0,
+			[]string{"line1\nline2", "line3"},
+		},
+		{
+			// 没有切开时保留末尾的换行，切开时只去掉作为分割点的那个
+			MsgPrint("short\n\n"),
+			10, 10,
+			[]string{"short\n\n"},
+		},
+		{
+			MsgPrint("line1\n\nline2\n"),
+			7, 0,
+			[]string{"line1\n", "line2\n"},
+		},
+		{
+			MsgPrint("abcdefghij"),
+			4, 0,
+			[]string{"abcd", "efgh", "ij"},
+		},
+		{
+			MsgPrint("a", MsgFactory.Face(1), "b", MsgFactory.Face(2), "c"),
+			0, 2,
+			[]string{"a[CQ:face,id=1]", "b[CQ:face,id=2]", "c"},
+		},
+		{
+			MsgPrint("一二三\n", MsgFactory.AtAll(), "四五六"),
+			4, 0,
+			[]string{"一二三\n[CQ:at,qq=all]", "四五六"},
+		},
+	}
+
+	for i, data := range testData {
+		chunks := data.msg.Split(data.maxChars, data.maxSegments)
+		if len(chunks) != len(data.expect) {
+			t.Errorf("%d: got %d chunks %v, expect %d", i, len(chunks), chunks, len(data.expect))
+			continue
+		}
+		for j, chunk := range chunks {
+			if chunk.String() != data.expect[j] {
+				t.Errorf("%d-%d: %q != %q", i, j, chunk.String(), data.expect[j])
+			}
+		}
+	}
+}
+
+func Test_ParseCQString(t *testing.T) {
+	testData := []struct {
+		str    string
+		expect Message
+	}{
+		{"hello", Message{MsgFactory.Text("hello")}},
+		{"", Message{}},
+		{
+			"[CQ:at,qq=114514] 你好&#91;&amp;&#93;",
+			Message{MsgFactory.AtSomeone(114514), MsgFactory.Text(" 你好[&]")},
+		},
+		{
+			"[CQ:shake][CQ:face,id=1]",
+			Message{MsgFactory.Shake(), MsgFactory.Face(1)},
+		},
+		{
+			"[CQ:share,url=https://a.com/?a=1&#44;2,title=t]",
+			Message{MsgFactory.Share("https://a.com/?a=1,2", "t", nil)},
+		},
+		{
+			"a[b]c",
+			Message{MsgFactory.Text("a[b]c")},
+		},
+	}
+	for i, data := range testData {
+		msg, err := ParseCQString(data.str)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if fmt.Sprintf("%#v", msg) != fmt.Sprintf("%#v", data.expect) {
+			t.Errorf("%d: %#v != %#v", i, msg, data.expect)
+		}
+	}
+
+	for _, str := range []string{"[CQ:at,qq=1", "[CQ:,qq=1]", "[CQ:at,qq]", "[CQ:at,qq=[1]", "[CQ:a t]"} {
+		if _, err := ParseCQString(str); !errors.Is(err, ErrInvalidCQCode) {
+			t.Errorf("%q 应解析失败，实际err为%v", str, err)
+		}
+	}
+}
+
+func Test_ConvertStringMessageEvent(t *testing.T) {
+	ev := ConvertJsonObjectToEvent(gjson.Parse(`{
+		"post_type": "message", "message_type": "group", "sub_type": "normal",
+		"self_id": 10000, "user_id": 1919810, "group_id": 114514, "message_id": 1,
+		"message": "[CQ:at,qq=10000] 你好", "raw_message": "[CQ:at,qq=10000] 你好",
+		"sender": {"user_id": 1919810}
+	}`))
+	gev, ok := ev.(*GroupMessageEvent)
+	if !ok {
+		t.Fatalf("事件类型错误：%T", ev)
+	}
+	if len(gev.Message) != 2 || gev.Message[0].Type != "at" || gev.Message[1].Data["text"] != " 你好" {
+		t.Errorf("消息解析错误：%#v", gev.Message)
+	}
+	if !gev.IsToMe() {
+		t.Error("At了bot的消息应为ToMe")
+	}
+}
+
+func Test_SegmentJSON(t *testing.T) {
+	b, _ := json.Marshal(MsgFactory.Shake())
+	if string(b) != `{"type":"shake","data":{}}` {
+		t.Errorf("空data应序列化为{}，实际为%s", b)
+	}
+
+	var msg Message
+	err := json.Unmarshal([]byte(`[{"type":"at","data":{"qq":1234567890123}},{"type":"text","data":{"text":"a,b"}}]`), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.String() != "[CQ:at,qq=1234567890123]a,b" {
+		t.Errorf("数字类型的参数值应原样保留，实际为%s", msg.String())
+	}
+	b, _ = json.Marshal(msg)
+	if string(b) != `[{"type":"at","data":{"qq":1234567890123}},{"type":"text","data":{"text":"a,b"}}]` {
+		t.Errorf("重新序列化后应保持原样，实际为%s", b)
+	}
+
+	seg := MsgFactory.Share("https://a.com/?a=1,2", "t", nil)
+	if seg.String() != "[CQ:share,title=t,url=https://a.com/?a=1&#44;2]" {
+		t.Errorf("参数值中的逗号应被转义，实际为%s", seg.String())
+	}
+}
+
+func Test_TypedSegment(t *testing.T) {
+	var msg Message
+	json.Unmarshal([]byte(`[
+		{"type":"reply","data":{"id":"-12345"}},
+		{"type":"at","data":{"qq":10000}},
+		{"type":"at","data":{"qq":"20000"}},
+		{"type":"at","data":{"qq":"all"}},
+		{"type":"image","data":{"file":"a.jpg","url":"http://x/a.jpg","type":"flash"}},
+		{"type":"unknown","data":{}}
+	]`), &msg)
+
+	if targets := msg.AtTargets(); len(targets) != 2 || targets[0] != 10000 || targets[1] != 20000 {
+		t.Errorf("AtTargets错误：%v", targets)
+	}
+	if !msg.HasAtMe(20000) || msg.HasAtMe(30000) || !msg.HasAtAll() {
+		t.Error("HasAtMe或HasAtAll错误")
+	}
+	if id, ok := msg.ReplyTo(); !ok || id != -12345 {
+		t.Errorf("ReplyTo错误：%d %v", id, ok)
+	}
+	if _, ok := MsgPrint("a").ReplyTo(); ok {
+		t.Error("没有回复时ReplyTo应返回false")
+	}
+	images := msg.Images()
+	if len(images) != 1 || images[0].File != "a.jpg" || images[0].Url != "http://x/a.jpg" || images[0].Type != "flash" || !images[0].Cache {
+		t.Errorf("Images错误：%+v", images)
+	}
+	if typed := msg.Typed(); len(typed) != 5 {
+		t.Errorf("不支持的消息段应被跳过，实际为%v", typed)
+	}
+
+	// 类型化消息段转回MessageSegment后应与工厂函数构造的一致
+	for _, seg := range []MessageSegment{
+		MsgFactory.Face(1),
+		MsgFactory.AtSomeone(114514),
+		MsgFactory.AtAll(),
+		MsgFactory.Poke(1, 2),
+		MsgFactory.ContactGroup(114514),
+		MsgFactory.Location(1.5, 2.5, MsgFactory.LocationOptions().SetTitle("t")),
+		MsgFactory.Music("1", MusicType_163),
+		MsgFactory.Reply(1),
+		MsgFactory.NodeCustom(1, "n", MsgPrint("c")),
+		MsgFactory.Image("a.jpg", nil),
+		MsgFactory.Record("a.amr", nil),
+	} {
+		typed, ok := seg.Typed()
+		if !ok {
+			t.Errorf("%s 应支持转换", seg.Type)
+			continue
+		}
+		if typed.ToSegment().String() != seg.String() {
+			t.Errorf("%s 转换前后不一致：%s != %s", seg.Type, typed.ToSegment(), seg)
+		}
+	}
+}
+
+type embeddedTemplateData struct {
+	Face MessageSegment
+}
+
+type templateData struct {
+	Name string
+	Img  MessageSegment
+	embeddedTemplateData
+}
+
+func Test_MsgTemplate(t *testing.T) {
+	tests := []struct {
+		src  string
+		data interface{}
+		want string
+	}{
+		{"欢迎{user}！", map[string]interface{}{"user": "张三"}, "欢迎张三！"},
+		{"{a.b}-{{.c}}", map[string]interface{}{"a": map[string]int{"b": 1}, "c": 2}, "1-2"},
+		{"{{if .ok}}是{{else}}否{{end}}", map[string]bool{"ok": true}, "是"},
+		{"{{range .items}}[{.}]{{end}}", map[string][]string{"items": {"x", "y"}}, "&#91;x&#93;&#91;y&#93;"},
+		{"{}与{ 1 }原样保留", nil, "{}与{ 1 }原样保留"},
+		{"看{img}", map[string]interface{}{"img": MsgFactory.Image("a.jpg", nil)}, "看[CQ:image,cache=1,file=a.jpg,proxy=1]"},
+		{"{{at .uid}} 你好", map[string]int64{"uid": 114514}, "[CQ:at,qq=114514] 你好"},
+		{"{m}", map[string]interface{}{"m": MsgPrint("[CQ:x]", MsgFactory.Face(1))}, "&#91;CQ:x&#93;[CQ:face,id=1]"},
+		{"价格{a", nil, "价格{a"},
+		{"{a}{", map[string]int{"a": 1}, "1{"},
+		{"{Name}{Img}{Face}", templateData{Name: "a", Img: MsgFactory.Image("a.jpg", nil), embeddedTemplateData: embeddedTemplateData{Face: MsgFactory.Face(1)}}, "a[CQ:image,cache=1,file=a.jpg,proxy=1][CQ:face,id=1]"},
+		{"{Img}", &templateData{Img: MsgFactory.Face(2)}, "[CQ:face,id=2]"},
+	}
+	for _, tt := range tests {
+		msg, err := MustParseMsgTemplate(tt.src).Render(tt.data)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if msg.String() != tt.want {
+			t.Errorf("%s 渲染结果为 %s，应为 %s", tt.src, msg.String(), tt.want)
+		}
+	}
+
+	// 从插件配置载入模板
+	type Config struct {
+		Welcome MsgTemplate
+		Bye     *MsgTemplate
+	}
+	cfg := Config{}
+	convertConfigMapToStruct(&cfg, PluginConfigMap{"welcome": "hi {name}", "bye": "bye"})
+	if msg := cfg.Welcome.MustRender(map[string]string{"name": "a"}); msg.String() != "hi a" {
+		t.Errorf("从配置载入的模板渲染结果为 %s", msg)
+	}
+	if cfg.Bye == nil || cfg.Bye.String() != "bye" {
+		t.Error("指针字段应能载入模板")
+	}
+}
+
+func Test_MessageUtils(t *testing.T) {
+	at := MsgFactory.AtSomeone(10086)
+	img := MsgFactory.Image("a.jpg", nil)
+
+	msg := Message{MsgFactory.Text(" 你"), MsgFactory.Text("好 "), at, MsgFactory.Text(""), MsgFactory.Text("  在吗 "), img}
+	if got := msg.Normalize().String(); got != " 你好[CQ:at,qq=10086]在吗 "+img.String() {
+		t.Errorf("Normalize结果为 %s", got)
+	}
+	if got := msg.TrimSpace().String(); got != "你好 [CQ:at,qq=10086]  在吗 "+img.String() {
+		t.Errorf("TrimSpace结果为 %s", got)
+	}
+
+	if !MsgPrint("ab", "c", at).Equal(Message{MsgFactory.Text("abc"), {Type: "at", Data: msgSegData{"qq": "10086"}}}) {
+		t.Error("文本分段方式与参数类型不同时应视为相同")
+	}
+	if MsgPrint("abc").Equal(MsgPrint("abc", at)) {
+		t.Error("内容不同时应视为不同")
+	}
+	if !msg.Contains("image") || msg.Contains("face") {
+		t.Error("Contains结果错误")
+	}
+
+	replaced := msg.Replace(func(seg MessageSegment) Message {
+		if seg.Type == "at" {
+			return nil
+		}
+		return Message{seg}
+	})
+	if replaced.Contains("at") || len(replaced) != len(msg)-1 {
+		t.Errorf("Replace结果为 %s", replaced)
+	}
+
+	slices := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 2, "ab"},
+		{2, 4, "c[CQ:at,qq=10086]d"},
+		{4, -1, "ef" + img.String()},
+		{1, 100, "bc[CQ:at,qq=10086]def" + img.String()},
+	}
+	src := MsgPrint("abc", at, "def", img)
+	for _, tt := range slices {
+		if got := src.SliceText(tt.start, tt.end).String(); got != tt.want {
+			t.Errorf("SliceText(%d, %d)结果为 %s，应为 %s", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	reply := MsgFactory.Reply(1)
+	stripped, ok := Message{reply, at, MsgFactory.Text(" /cmd")}.StripLeadingAt(10086)
+	if !ok || stripped.String() != reply.String()+"/cmd" {
+		t.Errorf("StripLeadingAt结果为 %s", stripped)
+	}
+	if _, ok = MsgPrint("hi", at).StripLeadingAt(10086); ok {
+		t.Error("At不在开头时不应去除")
+	}
+}
+
+func Test_RemainMsg(t *testing.T) {
+	bot := &Bot{botState: &botState{selfId: 10086}}
+	img := MsgFactory.Image("a.jpg", nil)
+	ev := &GroupMessageEvent{}
+	ev.PostType = PostType_MessageEvent
+	ev.Message = Message{MsgFactory.AtSomeone(10086), MsgFactory.Text("  echo hi "), img}
+
+	ctx := &Context{Event: ev, Keys: make(map[string]interface{}), Bot: bot}
+	if !StartsWith("echo")(ctx) {
+		t.Fatal("应匹配前缀")
+	}
+	if got := ctx.GetPrefixMatchResult().RemainMsg.String(); got != "hi "+img.String() {
+		t.Errorf("RemainMsg为 %s", got)
+	}
+}
+
+func Test_MediaFromBytes(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nrest")
+	seg, err := MsgFactory.ImageFromBytes(png, nil)
+	if err != nil || seg.Data["file"] != "base64://"+base64.StdEncoding.EncodeToString(png) {
+		t.Errorf("ImageFromBytes结果为 %v, %v", seg, err)
+	}
+	if _, err = MsgFactory.RecordFromBytes(png, nil); !errors.Is(err, ErrUnsupportedMediaFormat) {
+		t.Errorf("图片不应作为语音发送，err=%v", err)
+	}
+	if _, err = MsgFactory.VideoFromReader(strings.NewReader("\x00\x00\x00\x18ftypmp42"), nil); err != nil {
+		t.Error(err)
+	}
+
+	// 配置中的设置，重新载入后立即生效
+	bot, _ := newTestBot()
+	bot.config.store(&BaseConfig{Provider: "p", ProviderConfig: map[string]ProviderConfigMap{"p": {"media_max_size": 1}}})
+	if opt := bot.MsgFactory().mediaOptions(); opt.MaxSize != 1<<20 || opt.SharedFilesystem {
+		t.Errorf("应读取配置中的设置，实际为%+v", opt)
+	}
+	bot.config.store(&BaseConfig{Provider: "p", ProviderConfig: map[string]ProviderConfigMap{"p": {"shared_filesystem": true}}})
+	if opt := bot.MsgFactory().mediaOptions(); opt.MaxSize != defaultMediaMaxSize || !opt.SharedFilesystem {
+		t.Errorf("配置更新后应使用新的设置，实际为%+v", opt)
+	}
+	if opt := MsgFactory.mediaOptions(); opt != defaultMediaOptions {
+		t.Errorf("MsgFactory应使用默认设置，实际为%+v", opt)
+	}
+
+	factory := messageSegmentFactory{media: &mediaOptions{MaxSize: 4}}
+	if _, err = factory.ImageFromReader(bytes.NewReader(png), nil); !errors.Is(err, ErrMediaTooLarge) {
+		t.Errorf("超出大小上限时应返回ErrMediaTooLarge，err=%v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "a.png")
+	os.WriteFile(path, png, 0644)
+	factory = messageSegmentFactory{media: &mediaOptions{MaxSize: 1 << 20, SharedFilesystem: true}}
+	seg, err = factory.ImageFromFile(path, nil)
+	if err != nil || seg.Data["file"] != "file://"+filepath.ToSlash(path) {
+		t.Errorf("共享文件系统时应使用file URI，结果为 %v, %v", seg, err)
+	}
+	seg, _ = MsgFactory.ImageFromFile(path, nil)
+	if !strings.HasPrefix(seg.Data["file"].(string), "base64://") {
+		t.Errorf("不共享文件系统时应使用base64，结果为 %v", seg)
+	}
+}
+
+func Test_ExtendedSegment(t *testing.T) {
+	for _, seg := range []MessageSegment{
+		MsgFactory.FlashImage("a.jpg"),
+		MsgFactory.ShowImage("a.jpg", ShowImageEffect_Shake),
+		MsgFactory.AtWithName(114514, "田所"),
+		MsgFactory.CardImage("a.jpg", MsgFactory.CardImageOptions().SetMaxWidth(300).SetSource("s")),
+		MsgFactory.Gift(114514, 3),
+		MsgFactory.Redbag("恭喜发财"),
+		MsgFactory.Forward("abc"),
+	} {
+		typed, ok := seg.Typed()
+		if !ok {
+			t.Errorf("%s 应支持转换", seg.Type)
+			continue
+		}
+		if typed.ToSegment().String() != seg.String() {
+			t.Errorf("%s 转换前后不一致：%s != %s", seg.Type, typed.ToSegment(), seg)
+		}
+	}
+
+	msg, _ := ParseCQString("[CQ:image,file=a.jpg,type=show,id=40002][CQ:at,qq=1,name=张三][CQ:forward,id=xyz]")
+	typed := msg.Typed()
+	if img := typed[0].(ImageSegment); !img.IsShow() || img.Id != ShowImageEffect_Shake {
+		t.Errorf("秀图解析错误：%+v", img)
+	}
+	if at := typed[1].(AtSegment); at.Name != "张三" {
+		t.Errorf("At名称解析错误：%+v", at)
+	}
+	if fwd := typed[2].(ForwardSegment); fwd.Id != "xyz" {
+		t.Errorf("合并转发解析错误：%+v", fwd)
+	}
+}
@@ -0,0 +1,707 @@
+package gonebot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// 类型化的消息段，可与MessageSegment互相转换。
+//
+// 协议端上报的消息段中，数字可能是字符串、也可能是数字，转换时会统一处理，无需再对interface{}做类型断言。
+type TypedSegment interface {
+	SegmentType() string       // 消息段类型
+	ToSegment() MessageSegment // 转换为MessageSegment
+}
+
+// 将消息段转换为对应的类型化消息段，不支持的类型返回false
+func (seg MessageSegment) Typed() (TypedSegment, bool) {
+	decode, ok := typedSegmentDecoders[seg.Type]
+	if !ok {
+		return nil, false
+	}
+	return decode(seg.Data), true
+}
+
+// 将消息中所有支持的消息段转换为类型化消息段，不支持的类型将被跳过
+func (m Message) Typed() []TypedSegment {
+	ret := make([]TypedSegment, 0, len(m))
+	for _, seg := range m {
+		if ts, ok := seg.Typed(); ok {
+			ret = append(ret, ts)
+		}
+	}
+	return ret
+}
+
+// 消息中At的所有QQ号，不包括At全体成员
+func (m Message) AtTargets() []int64 {
+	ret := make([]int64, 0)
+	for _, seg := range m.FilterByType("at") {
+		at := decodeAtSegment(seg.Data).(AtSegment)
+		if !at.All {
+			ret = append(ret, at.QQ)
+		}
+	}
+	return ret
+}
+
+// 消息中是否At了全体成员
+func (m Message) HasAtAll() bool {
+	for _, seg := range m.FilterByType("at") {
+		if decodeAtSegment(seg.Data).(AtSegment).All {
+			return true
+		}
+	}
+	return false
+}
+
+// 消息中是否At了selfId
+func (m Message) HasAtMe(selfId int64) bool {
+	for _, qq := range m.AtTargets() {
+		if qq == selfId {
+			return true
+		}
+	}
+	return false
+}
+
+// 消息中的所有图片
+func (m Message) Images() []ImageSegment {
+	ret := make([]ImageSegment, 0)
+	for _, seg := range m.FilterByType("image") {
+		ret = append(ret, decodeImageSegment(seg.Data).(ImageSegment))
+	}
+	return ret
+}
+
+// 消息回复的消息ID。消息中没有回复消息段时，返回false
+func (m Message) ReplyTo() (int32, bool) {
+	segs := m.FilterByType("reply")
+	if len(segs) == 0 {
+		return 0, false
+	}
+	return decodeReplySegment(segs[0].Data).(ReplySegment).Id, true
+}
+
+// 读取字符串参数，不存在时返回空字符串
+func (d msgSegData) getString(key string) string {
+	v, ok := d[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// 读取整数参数，兼容字符串与各种数字类型，无法转换时返回0
+func (d msgSegData) getInt64(key string) int64 {
+	switch v := d[key].(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// 读取浮点数参数，兼容字符串与各种数字类型，无法转换时返回0
+func (d msgSegData) getFloat64(key string) float64 {
+	switch v := d[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+// 读取布尔参数，兼容"1"/"0"、"true"/"false"与数字，不存在时返回def
+func (d msgSegData) getBool(key string, def bool) bool {
+	v, ok := d[key]
+	if !ok || v == nil {
+		return def
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	switch d.getString(key) {
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	}
+	return def
+}
+
+// 读取消息参数，兼容Message、消息段数组、JSON数组及CQ码字符串
+func (d msgSegData) getMessage(key string) Message {
+	switch v := d[key].(type) {
+	case nil:
+		return nil
+	case Message:
+		return v
+	case []MessageSegment:
+		return v
+	case string:
+		return parseCQStringOrText(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var msg Message
+		json.Unmarshal(b, &msg)
+		return msg
+	}
+}
+
+var typedSegmentDecoders = map[string]func(msgSegData) TypedSegment{
+	"text":      decodeTextSegment,
+	"face":      decodeFaceSegment,
+	"image":     decodeImageSegment,
+	"record":    decodeRecordSegment,
+	"video":     decodeVideoSegment,
+	"at":        decodeAtSegment,
+	"rps":       func(msgSegData) TypedSegment { return RpsSegment{} },
+	"dice":      func(msgSegData) TypedSegment { return DiceSegment{} },
+	"shake":     func(msgSegData) TypedSegment { return ShakeSegment{} },
+	"poke":      decodePokeSegment,
+	"anonymous": decodeAnonymousSegment,
+	"share":     decodeShareSegment,
+	"contact":   decodeContactSegment,
+	"location":  decodeLocationSegment,
+	"music":     decodeMusicSegment,
+	"reply":     decodeReplySegment,
+	"node":      decodeNodeSegment,
+	"xml":       decodeXMLSegment,
+	"json":      decodeJSONSegment,
+	"tts":       decodeTTSSegment,
+	"cardimage": decodeCardImageSegment,
+	"gift":      decodeGiftSegment,
+	"redbag":    decodeRedbagSegment,
+	"forward":   decodeForwardSegment,
+}
+
+// 纯文本
+type TextSegment struct {
+	Text string
+}
+
+func decodeTextSegment(d msgSegData) TypedSegment {
+	return TextSegment{Text: d.getString("text")}
+}
+
+func (s TextSegment) SegmentType() string       { return "text" }
+func (s TextSegment) ToSegment() MessageSegment { return MsgFactory.Text(s.Text) }
+
+// QQ表情
+type FaceSegment struct {
+	Id int
+}
+
+func decodeFaceSegment(d msgSegData) TypedSegment {
+	return FaceSegment{Id: int(d.getInt64("id"))}
+}
+
+func (s FaceSegment) SegmentType() string       { return "face" }
+func (s FaceSegment) ToSegment() MessageSegment { return MsgFactory.Face(s.Id) }
+
+// 图片
+type ImageSegment struct {
+	File    string // 文件名、网络URL、本地URI或Base64
+	Type    string // 图片类型，"flash"表示闪照，"show"表示秀图（go-cqhttp扩展），空表示普通图片
+	SubType int    // go-cqhttp扩展。图片子类型，0为普通图片
+	Id      int    // go-cqhttp扩展。秀图特效ID，见ShowImageEffect_*
+	Url     string // 图片URL，仅收到的消息中有
+	Cache   bool
+	Proxy   bool
+	Timeout int
+}
+
+func decodeImageSegment(d msgSegData) TypedSegment {
+	return ImageSegment{
+		File:    d.getString("file"),
+		Type:    d.getString("type"),
+		SubType: int(d.getInt64("subType")),
+		Id:      int(d.getInt64("id")),
+		Url:     d.getString("url"),
+		Cache:   d.getBool("cache", true),
+		Proxy:   d.getBool("proxy", true),
+		Timeout: int(d.getInt64("timeout")),
+	}
+}
+
+// 是否为闪照
+func (s ImageSegment) IsFlash() bool {
+	return s.Type == "flash"
+}
+
+// 是否为秀图
+func (s ImageSegment) IsShow() bool {
+	return s.Type == "show"
+}
+
+func (s ImageSegment) SegmentType() string { return "image" }
+
+func (s ImageSegment) ToSegment() MessageSegment {
+	opt := MsgFactory.ImageOptions().SetCache(s.Cache).SetProxy(s.Proxy)
+	if s.Type != "" {
+		opt.SetType(s.Type)
+	}
+	if s.Timeout > 0 {
+		opt.SetTimeout(s.Timeout)
+	}
+	if s.SubType != 0 {
+		opt.SetSubType(s.SubType)
+	}
+	if s.Id != 0 {
+		opt.SetId(s.Id)
+	}
+	seg := MsgFactory.Image(s.File, opt)
+	if s.Url != "" {
+		seg.Data["url"] = s.Url
+	}
+	return seg
+}
+
+// 语音
+type RecordSegment struct {
+	File    string
+	Url     string // 语音URL，仅收到的消息中有
+	Magic   bool   // 是否变声
+	Cache   bool
+	Proxy   bool
+	Timeout int
+}
+
+func decodeRecordSegment(d msgSegData) TypedSegment {
+	return RecordSegment{
+		File:    d.getString("file"),
+		Url:     d.getString("url"),
+		Magic:   d.getBool("magic", false),
+		Cache:   d.getBool("cache", true),
+		Proxy:   d.getBool("proxy", true),
+		Timeout: int(d.getInt64("timeout")),
+	}
+}
+
+func (s RecordSegment) SegmentType() string { return "record" }
+
+func (s RecordSegment) ToSegment() MessageSegment {
+	opt := MsgFactory.RecordOptions().SetMagic(s.Magic).SetCache(s.Cache).SetProxy(s.Proxy)
+	if s.Timeout > 0 {
+		opt.SetTimeout(s.Timeout)
+	}
+	seg := MsgFactory.Record(s.File, opt)
+	if s.Url != "" {
+		seg.Data["url"] = s.Url
+	}
+	return seg
+}
+
+// 视频
+type VideoSegment struct {
+	File    string
+	Url     string // 视频URL，仅收到的消息中有
+	Cache   bool
+	Proxy   bool
+	Timeout int
+}
+
+func decodeVideoSegment(d msgSegData) TypedSegment {
+	return VideoSegment{
+		File:    d.getString("file"),
+		Url:     d.getString("url"),
+		Cache:   d.getBool("cache", true),
+		Proxy:   d.getBool("proxy", true),
+		Timeout: int(d.getInt64("timeout")),
+	}
+}
+
+func (s VideoSegment) SegmentType() string { return "video" }
+
+func (s VideoSegment) ToSegment() MessageSegment {
+	opt := MsgFactory.VideoOptions().SetCache(s.Cache).SetProxy(s.Proxy)
+	if s.Timeout > 0 {
+		opt.SetTimeout(s.Timeout)
+	}
+	seg := MsgFactory.Video(s.File, opt)
+	if s.Url != "" {
+		seg.Data["url"] = s.Url
+	}
+	return seg
+}
+
+// At。All为true时表示At全体成员，此时QQ为0
+type AtSegment struct {
+	QQ   int64
+	All  bool
+	Name string // go-cqhttp扩展。QQ号不在群内时显示的名称
+}
+
+func decodeAtSegment(d msgSegData) TypedSegment {
+	if d.getString("qq") == "all" {
+		return AtSegment{All: true}
+	}
+	return AtSegment{QQ: d.getInt64("qq"), Name: d.getString("name")}
+}
+
+func (s AtSegment) SegmentType() string { return "at" }
+
+func (s AtSegment) ToSegment() MessageSegment {
+	if s.All {
+		return MsgFactory.AtAll()
+	}
+	if s.Name != "" {
+		return MsgFactory.AtWithName(s.QQ, s.Name)
+	}
+	return MsgFactory.AtSomeone(s.QQ)
+}
+
+// 猜拳魔法表情
+type RpsSegment struct{}
+
+func (s RpsSegment) SegmentType() string       { return "rps" }
+func (s RpsSegment) ToSegment() MessageSegment { return MsgFactory.Rps() }
+
+// 掷骰子魔法表情
+type DiceSegment struct{}
+
+func (s DiceSegment) SegmentType() string       { return "dice" }
+func (s DiceSegment) ToSegment() MessageSegment { return MsgFactory.Dice() }
+
+// 窗口抖动
+type ShakeSegment struct{}
+
+func (s ShakeSegment) SegmentType() string       { return "shake" }
+func (s ShakeSegment) ToSegment() MessageSegment { return MsgFactory.Shake() }
+
+// 戳一戳
+type PokeSegment struct {
+	Type int
+	Id   int
+	Name string // 表情名，仅收到的消息中有
+}
+
+func decodePokeSegment(d msgSegData) TypedSegment {
+	return PokeSegment{
+		Type: int(d.getInt64("type")),
+		Id:   int(d.getInt64("id")),
+		Name: d.getString("name"),
+	}
+}
+
+func (s PokeSegment) SegmentType() string       { return "poke" }
+func (s PokeSegment) ToSegment() MessageSegment { return MsgFactory.Poke(s.Type, s.Id) }
+
+// 匿名发消息
+type AnonymousSegment struct {
+	Ignore bool // 无法匿名时是否继续发送
+}
+
+func decodeAnonymousSegment(d msgSegData) TypedSegment {
+	return AnonymousSegment{Ignore: d.getBool("ignore", false)}
+}
+
+func (s AnonymousSegment) SegmentType() string       { return "anonymous" }
+func (s AnonymousSegment) ToSegment() MessageSegment { return MsgFactory.AnonymousSegment(s.Ignore) }
+
+// 链接分享
+type ShareSegment struct {
+	Url     string
+	Title   string
+	Content string
+	Image   string
+}
+
+func decodeShareSegment(d msgSegData) TypedSegment {
+	return ShareSegment{
+		Url:     d.getString("url"),
+		Title:   d.getString("title"),
+		Content: d.getString("content"),
+		Image:   d.getString("image"),
+	}
+}
+
+func (s ShareSegment) SegmentType() string { return "share" }
+
+func (s ShareSegment) ToSegment() MessageSegment {
+	opt := MsgFactory.ShareOptions()
+	if s.Content != "" {
+		opt.SetContent(s.Content)
+	}
+	seg := MsgFactory.Share(s.Url, s.Title, opt)
+	if s.Image != "" {
+		seg.Data["image"] = s.Image
+	}
+	return seg
+}
+
+// 推荐好友或群。Type为"qq"或"group"
+type ContactSegment struct {
+	Type string
+	Id   int64
+}
+
+func decodeContactSegment(d msgSegData) TypedSegment {
+	return ContactSegment{Type: d.getString("type"), Id: d.getInt64("id")}
+}
+
+func (s ContactSegment) SegmentType() string { return "contact" }
+
+func (s ContactSegment) ToSegment() MessageSegment {
+	if s.Type == "group" {
+		return MsgFactory.ContactGroup(s.Id)
+	}
+	return MsgFactory.ContactQQ(s.Id)
+}
+
+// 位置
+type LocationSegment struct {
+	Lat     float64
+	Lng     float64
+	Title   string
+	Content string
+}
+
+func decodeLocationSegment(d msgSegData) TypedSegment {
+	lng := d.getFloat64("lon")
+	if _, ok := d["lon"]; !ok {
+		lng = d.getFloat64("lng")
+	}
+	return LocationSegment{
+		Lat:     d.getFloat64("lat"),
+		Lng:     lng,
+		Title:   d.getString("title"),
+		Content: d.getString("content"),
+	}
+}
+
+func (s LocationSegment) SegmentType() string { return "location" }
+
+func (s LocationSegment) ToSegment() MessageSegment {
+	opt := MsgFactory.LocationOptions()
+	if s.Title != "" {
+		opt.SetTitle(s.Title)
+	}
+	if s.Content != "" {
+		opt.SetContent(s.Content)
+	}
+	return MsgFactory.Location(s.Lat, s.Lng, opt)
+}
+
+// 音乐分享。Type为"custom"时表示自定义分享，此时Id为空，其余字段有效
+type MusicSegment struct {
+	Type    string
+	Id      string
+	Url     string
+	Audio   string
+	Title   string
+	Content string
+	Image   string
+}
+
+func decodeMusicSegment(d msgSegData) TypedSegment {
+	return MusicSegment{
+		Type:    d.getString("type"),
+		Id:      d.getString("id"),
+		Url:     d.getString("url"),
+		Audio:   d.getString("audio"),
+		Title:   d.getString("title"),
+		Content: d.getString("content"),
+		Image:   d.getString("image"),
+	}
+}
+
+func (s MusicSegment) SegmentType() string { return "music" }
+
+func (s MusicSegment) ToSegment() MessageSegment {
+	if s.Type != "custom" {
+		return MsgFactory.Music(s.Id, s.Type)
+	}
+	opt := MsgFactory.CustomMusicParams()
+	if s.Content != "" {
+		opt.SetContent(s.Content)
+	}
+	if s.Image != "" {
+		opt.SetImage(s.Image)
+	}
+	return MsgFactory.CustomMusic(s.Url, s.Title, s.Audio, opt)
+}
+
+// 回复
+type ReplySegment struct {
+	Id int32 // 被回复的消息ID
+}
+
+func decodeReplySegment(d msgSegData) TypedSegment {
+	return ReplySegment{Id: int32(d.getInt64("id"))}
+}
+
+func (s ReplySegment) SegmentType() string       { return "reply" }
+func (s ReplySegment) ToSegment() MessageSegment { return MsgFactory.Reply(int64(s.Id)) }
+
+// 合并转发节点。Id不为0时表示引用已有消息，否则为自定义节点
+type NodeSegment struct {
+	Id       int64
+	UserId   int64
+	Nickname string
+	Content  Message
+}
+
+func decodeNodeSegment(d msgSegData) TypedSegment {
+	return NodeSegment{
+		Id:       d.getInt64("id"),
+		UserId:   d.getInt64("user_id"),
+		Nickname: d.getString("nickname"),
+		Content:  d.getMessage("content"),
+	}
+}
+
+func (s NodeSegment) SegmentType() string { return "node" }
+
+func (s NodeSegment) ToSegment() MessageSegment {
+	if s.Id != 0 {
+		return MsgFactory.Node(s.Id)
+	}
+	return MsgFactory.NodeCustom(s.UserId, s.Nickname, s.Content)
+}
+
+// XML消息
+type XMLSegment struct {
+	Data string
+}
+
+func decodeXMLSegment(d msgSegData) TypedSegment {
+	return XMLSegment{Data: d.getString("data")}
+}
+
+func (s XMLSegment) SegmentType() string       { return "xml" }
+func (s XMLSegment) ToSegment() MessageSegment { return MsgFactory.XML(s.Data) }
+
+// JSON消息
+type JSONSegment struct {
+	Data string
+}
+
+func decodeJSONSegment(d msgSegData) TypedSegment {
+	return JSONSegment{Data: d.getString("data")}
+}
+
+func (s JSONSegment) SegmentType() string       { return "json" }
+func (s JSONSegment) ToSegment() MessageSegment { return MsgFactory.JSON(s.Data) }
+
+// 文本转语音
+type TTSSegment struct {
+	Text string
+}
+
+func decodeTTSSegment(d msgSegData) TypedSegment {
+	return TTSSegment{Text: d.getString("text")}
+}
+
+func (s TTSSegment) SegmentType() string       { return "tts" }
+func (s TTSSegment) ToSegment() MessageSegment { return MsgFactory.TTS(s.Text) }
+
+// go-cqhttp扩展。装逼大图
+type CardImageSegment struct {
+	File      string
+	MinWidth  int
+	MinHeight int
+	MaxWidth  int
+	MaxHeight int
+	Source    string
+	Icon      string
+}
+
+func decodeCardImageSegment(d msgSegData) TypedSegment {
+	return CardImageSegment{
+		File:      d.getString("file"),
+		MinWidth:  int(d.getInt64("minwidth")),
+		MinHeight: int(d.getInt64("minheight")),
+		MaxWidth:  int(d.getInt64("maxwidth")),
+		MaxHeight: int(d.getInt64("maxheight")),
+		Source:    d.getString("source"),
+		Icon:      d.getString("icon"),
+	}
+}
+
+func (s CardImageSegment) SegmentType() string { return "cardimage" }
+
+func (s CardImageSegment) ToSegment() MessageSegment {
+	opt := MsgFactory.CardImageOptions()
+	if s.MinWidth > 0 {
+		opt.SetMinWidth(s.MinWidth)
+	}
+	if s.MinHeight > 0 {
+		opt.SetMinHeight(s.MinHeight)
+	}
+	if s.MaxWidth > 0 {
+		opt.SetMaxWidth(s.MaxWidth)
+	}
+	if s.MaxHeight > 0 {
+		opt.SetMaxHeight(s.MaxHeight)
+	}
+	if s.Source != "" {
+		opt.SetSource(s.Source)
+	}
+	if s.Icon != "" {
+		opt.SetIcon(s.Icon)
+	}
+	return MsgFactory.CardImage(s.File, opt)
+}
+
+// go-cqhttp扩展。礼物
+type GiftSegment struct {
+	QQ int64 // 接收礼物的成员
+	Id int   // 礼物ID
+}
+
+func decodeGiftSegment(d msgSegData) TypedSegment {
+	return GiftSegment{QQ: d.getInt64("qq"), Id: int(d.getInt64("id"))}
+}
+
+func (s GiftSegment) SegmentType() string       { return "gift" }
+func (s GiftSegment) ToSegment() MessageSegment { return MsgFactory.Gift(s.QQ, s.Id) }
+
+// go-cqhttp扩展。红包，仅能接收
+type RedbagSegment struct {
+	Title string // 祝福语或口令
+}
+
+func decodeRedbagSegment(d msgSegData) TypedSegment {
+	return RedbagSegment{Title: d.getString("title")}
+}
+
+func (s RedbagSegment) SegmentType() string       { return "redbag" }
+func (s RedbagSegment) ToSegment() MessageSegment { return MsgFactory.Redbag(s.Title) }
+
+// go-cqhttp扩展。合并转发，仅能接收。内容需通过Bot.GetForwardMsgById获取
+type ForwardSegment struct {
+	Id string
+}
+
+func decodeForwardSegment(d msgSegData) TypedSegment {
+	return ForwardSegment{Id: d.getString("id")}
+}
+
+func (s ForwardSegment) SegmentType() string       { return "forward" }
+func (s ForwardSegment) ToSegment() MessageSegment { return MsgFactory.Forward(s.Id) }
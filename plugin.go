@@ -3,6 +3,8 @@ package gonebot
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,6 +19,8 @@ func init() {
 type pluginManager struct {
 	plugins             map[string]Plugin
 	pluginConfigStructs map[string]interface{}
+	pluginConfigInitial map[string]reflect.Value // 注册时配置结构体的值，重新载入配置时以此为初始值
+	pluginConfigCurrent map[string]*atomic.Value // 当前的配置结构体指针，重新载入配置时整体替换
 }
 
 var defaultPluginManager *pluginManager
@@ -25,6 +29,8 @@ func newPluginManager() *pluginManager {
 	pm := &pluginManager{
 		plugins:             make(map[string]Plugin),
 		pluginConfigStructs: make(map[string]interface{}),
+		pluginConfigInitial: make(map[string]reflect.Value),
+		pluginConfigCurrent: make(map[string]*atomic.Value),
 	}
 
 	return pm
@@ -39,38 +45,95 @@ func (pm *pluginManager) RegisterPlugin(plugin Plugin, pluginConfig interface{})
 
 	if pluginConfig != nil {
 		pm.pluginConfigStructs[id] = pluginConfig
+		pm.pluginConfigCurrent[id] = &atomic.Value{}
+		pm.pluginConfigCurrent[id].Store(pluginConfig)
+		if v := reflect.ValueOf(pluginConfig); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+			initial := reflect.New(v.Elem().Type()).Elem()
+			initial.Set(v.Elem())
+			pm.pluginConfigInitial[id] = initial
+		}
 	}
 }
 
 // 初始化插件
 func (pm *pluginManager) InitPlugins(engine *Engine) {
-	cfg := engine.Config.GetBaseConfig()
-	pluginControlConfig := cfg.Plugin.Enable
+	cfg := engine.GetConfig().GetBaseConfig()
 
-	for id, plugin := range pm.plugins {
-		// 仅当配置中指定为禁用的插件才不加载。配置中未指定的插件默认启用
-		if enabled, ok := pluginControlConfig[id]; ok && !enabled {
+	// 先载入所有插件的配置，配置有误的插件及依赖它的插件都不加载
+	invalid := make(map[string]error)
+	for id := range pm.plugins {
+		if enabled, ok := cfg.Plugin.Enable[id]; ok && !enabled {
 			continue
 		}
-
-		// 填充插件配置结构体字段
-		if plgCfgStruct, ok := pm.pluginConfigStructs[id]; ok {
-			convertConfigMapToStruct(plgCfgStruct, cfg.Plugin.Config[id])
+		if err := pm.loadPluginConfig(engine, id); err != nil {
+			invalid[id] = err
 		}
+	}
 
-		log.Debugf("正在初始化插件：%s", id)
-		hub := newPluginHub(engine)
-		hub.plugin = plugin
+	order, skipped := pm.resolveLoadOrder(cfg.Plugin.Enable, invalid)
+	for id, err := range skipped {
+		log.Errorf("插件%s不会被加载：%s", id, err)
+	}
 
-		log.Debugf("正在为插件%s运行PluginWillLoad钩子", id)
-		GlobalHooks.firePluginHook(pluginLifecycleHook_PluginWillLoad, &hub)
+	for _, id := range order {
+		pm.loadPlugin(engine, id)
+	}
+}
 
-		plugin.Init(&hub)
+// 填充插件配置结构体字段。插件尚未初始化，可以直接修改注册的结构体
+func (pm *pluginManager) loadPluginConfig(engine *Engine, id string) error {
+	v, err := pm.buildPluginConfig(id, engine.GetConfig().GetBaseConfig().Plugin.Config[id])
+	if err != nil || !v.IsValid() {
+		return err
+	}
+	registered := pm.pluginConfigStructs[id]
+	reflect.ValueOf(registered).Elem().Set(v)
+	pm.pluginConfigCurrent[id].Store(registered)
+	return nil
+}
 
-		log.Debugf("正在为插件%s运行PluginLoaded钩子", id)
-		GlobalHooks.firePluginHook(pluginLifecycleHook_PluginLoaded, &hub)
-		log.Infof("插件%s加载完毕", id)
+// 发布重新载入的插件配置。插件可能正在读取旧的结构体，不能原地修改，换成新的指针
+func (pm *pluginManager) publishPluginConfig(id string, v reflect.Value) {
+	pm.pluginConfigCurrent[id].Store(v.Addr().Interface())
+}
+
+// 以注册时的值为初始值，按配置生成新的插件配置，不修改已注册的结构体。插件没有配置时返回零值
+func (pm *pluginManager) buildPluginConfig(id string, src PluginConfigMap) (reflect.Value, error) {
+	plgCfgStruct, ok := pm.pluginConfigStructs[id]
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	initial, ok := pm.pluginConfigInitial[id]
+	if !ok {
+		// 不是结构体指针，由convertConfigMapToStruct报错
+		return reflect.Value{}, convertConfigMapToStruct(plgCfgStruct, src)
 	}
+	v := reflect.New(initial.Type())
+	v.Elem().Set(initial)
+	if err := convertConfigMapToStruct(v.Interface(), src); err != nil {
+		return reflect.Value{}, err
+	}
+	return v.Elem(), nil
+}
+
+// 初始化单个插件，需先载入配置
+func (pm *pluginManager) loadPlugin(engine *Engine, id string) *PluginHub {
+	plugin := pm.plugins[id]
+
+	log.Debugf("正在初始化插件：%s", id)
+	hub := newPluginHub(engine)
+	hub.plugin = plugin
+	engine.plugins.add(id, hub)
+
+	log.Debugf("正在为插件%s运行PluginWillLoad钩子", id)
+	GlobalHooks.firePluginHook(pluginLifecycleHook_PluginWillLoad, hub)
+
+	hub.init()
+
+	log.Debugf("正在为插件%s运行PluginLoaded钩子", id)
+	GlobalHooks.firePluginHook(pluginLifecycleHook_PluginLoaded, hub)
+	log.Infof("插件%s加载完毕", id)
+	return hub
 }
 
 func (pm *pluginManager) GetPluginById(id string) Plugin {
@@ -87,8 +150,8 @@ func (pm *pluginManager) GetPlugin(name, author string) Plugin {
 }
 
 func (pm *pluginManager) GetPluginConfig(plugin Plugin) interface{} {
-	if ret, ok := pm.pluginConfigStructs[getPluginId(plugin)]; ok {
-		return ret
+	if ret, ok := pm.pluginConfigCurrent[getPluginId(plugin)]; ok {
+		return ret.Load()
 	} else {
 		return nil
 	}
@@ -99,7 +162,8 @@ func RegisterPlugin(plugin Plugin, pluginConfig interface{}) {
 	defaultPluginManager.RegisterPlugin(plugin, pluginConfig)
 }
 
-// 获取插件配置
+// 获取插件当前的配置。配置文件重新载入后返回新的结构体指针，注册时传入的结构体不再更新，
+// 需要热重载的插件应在每次使用时调用
 func GetPluginConfig(plugin Plugin) interface{} {
 	return defaultPluginManager.GetPluginConfig(plugin)
 }
@@ -108,16 +172,48 @@ func GetPluginConfig(plugin Plugin) interface{} {
 type PluginInfo struct {
 	Name        string
 	Author      string
-	Version     string
+	Version     string // 语义化版本号，如1.2.3，被其他插件依赖时用于检查版本
 	Description string
+
+	Dependencies []PluginDependency // 依赖的插件，依赖的插件会先于本插件加载
+
+	// 以下用于帮助信息，均可不填
+	Usage      string           // 用法说明，可以多行
+	Examples   []string         // 示例命令
+	Category   string           // 分类，帮助中同一分类的插件放在一起
+	Visibility PluginVisibility // 在帮助中对谁可见，默认所有人
 }
 
+// 插件在帮助中的可见性
+type PluginVisibility int
+
+const (
+	PluginVisibility_Public    PluginVisibility = iota // 所有人可见
+	PluginVisibility_Superuser                         // 仅超级用户可见
+	PluginVisibility_Hidden                            // 不在帮助中显示
+)
+
 type Plugin interface {
 	Init(hub *PluginHub) // 初始化插件
 	GetPluginInfo() PluginInfo
 }
 
+// 配置文件重新载入后，GetPluginConfig已返回新的配置结构体，实现了该接口的插件会收到通知
+type ConfigChangeListener interface {
+	OnConfigChange(hub *PluginHub)
+}
+
+// 可卸载的插件。插件在运行时被禁用时调用Unload，用于停止插件自行开启的协程、定时器等。
+// 重新启用时会再次调用Init，可以在其中重新开启
+type Unloadable interface {
+	Unload(hub *PluginHub)
+}
+
 // 获取插件的唯一标识，格式为：“插件名@作者”
+func GetPluginId(plugin Plugin) string {
+	return getPluginId(plugin)
+}
+
 func getPluginId(plugin Plugin) string {
 	info := plugin.GetPluginInfo()
 	return fmt.Sprintf("%s@%s", info.Name, info.Author)
@@ -128,14 +224,51 @@ type PluginHub struct {
 	engine  *Engine
 	handler *Handler
 	plugin  Plugin
+
+	detach       func() // 从Engine上移除插件的Handler
+	enabled      bool
+	initializing bool // 是否正在执行Init
+	onUnload     []func()
+	jobs         map[*ScheduledJob]struct{} // 插件的定时任务
+	mu           sync.Mutex
+
+	stats   pluginStats    // 运行统计
+	breaker circuitBreaker // 多次panic或超时后暂停处理事件
 }
 
-func newPluginHub(engine *Engine) PluginHub {
-	ret := PluginHub{engine: engine}
-	ret.handler, _ = engine.NewRemovableHandler()
+func newPluginHub(engine *Engine) *PluginHub {
+	ret := &PluginHub{engine: engine, enabled: true}
+	ret.attach(0)
 	return ret
 }
 
+// 在Engine上添加插件的根Handler。seq为原来的根Handler的序号，重新启用时回到原来的位置，为0时放在最后
+func (p *PluginHub) attach(seq uint64) {
+	handler := newHandler()
+	if seq != 0 {
+		handler.seq = seq
+	}
+	handler.unloadSignal = make(chan struct{})
+	handler.hub = p
+	handler.Use(pluginBreakerMiddleware(p), pluginSwitchMiddleware(p))
+	p.handler = handler
+	p.detach = func() { p.engine.removeSubHandler(handler, EventName_AllEvent) }
+	p.engine.addSubHandler(handler, EventName_AllEvent)
+}
+
+// 调用插件的Init，期间添加的定时任务在重新启用时会随Init重新创建
+func (p *PluginHub) init() {
+	p.mu.Lock()
+	p.initializing = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.initializing = false
+		p.mu.Unlock()
+	}()
+	p.plugin.Init(p)
+}
+
 // 新建一个Handler，用于处理指定类型的事件，不写则处理所有类型的事件
 func (p *PluginHub) NewHandler(eventTypes ...EventName) *Handler {
 	return p.handler.NewHandler(eventTypes...)
@@ -146,7 +279,7 @@ func (p *PluginHub) Use(middlewares ...Middleware) {
 }
 
 func (p *PluginHub) GetEngineConfig() *BaseConfig {
-	return p.engine.Config.GetBaseConfig()
+	return p.engine.GetConfig().GetBaseConfig()
 }
 
 func (p *PluginHub) GetPluginConfig(plugin Plugin) interface{} {
@@ -161,15 +294,28 @@ func (p *PluginHub) GetPluginId() string {
 // 	return p.engine
 // }
 
+// 插件使用的Bot，调用API时计入插件的统计
 func (p *PluginHub) GetBot() *Bot {
-	return p.engine.bot
+	return p.engine.bot.forPlugin(&p.stats)
 }
 
-func convertConfigMapToStruct(cfgStruct interface{}, srcMap PluginConfigMap) {
-	if srcMap == nil {
-		return
-	}
+// 插件当前是否启用
+func (p *PluginHub) IsEnabled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enabled
+}
 
+// 注册插件被禁用时执行的清理函数，如停止自己开启的协程。每个函数只执行一次，重新启用时Init会再次被调用
+func (p *PluginHub) OnUnload(f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onUnload = append(p.onUnload, f)
+}
+
+// 将配置填充到结构体中：先填充默认值，再按配置覆盖，最后检查标签中的限制并调用Validate。
+// 所有错误会被汇总为一个ConfigErrors
+func convertConfigMapToStruct(cfgStruct interface{}, srcMap PluginConfigMap) error {
 	if cfgStruct == nil {
 		panic("cfgStruct不能为空")
 	}
@@ -185,5 +331,16 @@ func convertConfigMapToStruct(cfgStruct interface{}, srcMap PluginConfigMap) {
 		panic(fmt.Errorf("必须传入结构体指针，而不是 %v", value.Kind()))
 	}
 
-	mapToStruct(srcMap, value.Addr().Interface())
+	errs := ConfigErrors{}
+	applyConfigDefaults(value, "", &errs)
+	if srcMap != nil {
+		mapToStruct(srcMap, value.Addr().Interface(), "", &errs)
+	}
+	if len(errs) == 0 {
+		validateConfig(value, "", &errs)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
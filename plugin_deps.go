@@ -0,0 +1,335 @@
+package gonebot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 插件依赖
+type PluginDependency struct {
+	Id string // 依赖的插件，格式为“插件名@作者”
+	// 版本要求，如">=1.2.0"、"^1.2"、"~1.2.3"、"1.x"、">=1.0, <2.0"、"^1.0 || ^2.0"，不填则不限
+	Version string
+	// 可选依赖。已注册并启用时先于本插件加载；不存在、被禁用或版本不符时仍正常加载本插件
+	Optional bool
+}
+
+// 解析插件的加载顺序，被依赖的插件先加载。
+// failed为已知不能加载的插件（如配置有误）。
+// 返回按顺序排列的插件ID，以及不能加载的插件及原因
+func (pm *pluginManager) resolveLoadOrder(enable map[string]bool, failed map[string]error) (order []string, skipped map[string]error) {
+	skipped = make(map[string]error)
+	for id, err := range failed {
+		skipped[id] = err
+	}
+	disabled := make(map[string]bool)
+	ids := make([]string, 0, len(pm.plugins))
+	for id := range pm.plugins {
+		ids = append(ids, id)
+		// 仅当配置中指定为禁用的插件才不加载。配置中未指定的插件默认启用
+		if enabled, ok := enable[id]; ok && !enabled {
+			disabled[id] = true
+		}
+	}
+	sort.Strings(ids)
+
+	// 检查必需的依赖，不满足时不加载。被跳过的插件可能导致依赖它的插件也被跳过，因此重复检查直到没有变化
+	for changed := true; changed; {
+		changed = false
+		for _, id := range ids {
+			if disabled[id] || skipped[id] != nil {
+				continue
+			}
+			for _, dep := range pm.plugins[id].GetPluginInfo().Dependencies {
+				if dep.Optional {
+					continue
+				}
+				if err := pm.checkDependency(dep, disabled, skipped); err != nil {
+					skipped[id] = err
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	// Kahn算法拓扑排序，入度相同时按ID排序，保证每次的顺序一致
+	loadable := make(map[string]bool)
+	for _, id := range ids {
+		if !disabled[id] && skipped[id] == nil {
+			loadable[id] = true
+		}
+	}
+	inDegree := make(map[string]int)
+	dependents := make(map[string][]string)
+	for _, id := range ids {
+		if !loadable[id] {
+			continue
+		}
+		for _, dep := range pm.plugins[id].GetPluginInfo().Dependencies {
+			if !loadable[dep.Id] || dep.Optional && pm.checkDependency(dep, disabled, skipped) != nil {
+				continue
+			}
+			inDegree[id]++
+			dependents[dep.Id] = append(dependents[dep.Id], id)
+		}
+	}
+
+	queue := []string{}
+	for _, id := range ids {
+		if loadable[id] && inDegree[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+
+		next := []string{}
+		for _, d := range dependents[id] {
+			inDegree[d]--
+			if inDegree[d] == 0 {
+				next = append(next, d)
+			}
+		}
+		sort.Strings(next)
+		queue = append(queue, next...)
+		sort.Strings(queue)
+	}
+
+	// 剩下的插件处于循环依赖中，或依赖了循环中的插件
+	if len(order) < len(loadable) {
+		loaded := make(map[string]bool)
+		for _, id := range order {
+			loaded[id] = true
+		}
+		for _, id := range ids {
+			if loadable[id] && !loaded[id] {
+				skipped[id] = fmt.Errorf("%w: %s", ErrPluginDependencyCycle, pm.findCycle(id, loadable, loaded))
+			}
+		}
+	}
+	return
+}
+
+// 检查单个依赖是否满足
+func (pm *pluginManager) checkDependency(dep PluginDependency, disabled map[string]bool, skipped map[string]error) error {
+	plugin, ok := pm.plugins[dep.Id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPluginDependencyMissing, dep.Id)
+	}
+	if disabled[dep.Id] {
+		return fmt.Errorf("%w: %s", ErrPluginDependencyDisabled, dep.Id)
+	}
+	if err := skipped[dep.Id]; err != nil {
+		return fmt.Errorf("%w: %s（%s）", ErrPluginDependencyDisabled, dep.Id, err)
+	}
+	if dep.Version == "" {
+		return nil
+	}
+	version := plugin.GetPluginInfo().Version
+	ok, err := matchVersionConstraint(version, dep.Version)
+	if err != nil {
+		return fmt.Errorf("%w: %s %s", ErrPluginDependencyVersion, dep.Id, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: 需要%s %s，实际为%s", ErrPluginDependencyVersion, dep.Id, dep.Version, version)
+	}
+	return nil
+}
+
+// 从id出发沿依赖查找环，返回形如“a -> b -> a”的描述。id不在环上时返回其依赖路径
+func (pm *pluginManager) findCycle(id string, loadable, loaded map[string]bool) string {
+	path := []string{id}
+	visited := map[string]int{id: 0}
+	for cur := id; ; {
+		next := ""
+		for _, dep := range pm.plugins[cur].GetPluginInfo().Dependencies {
+			if loadable[dep.Id] && !loaded[dep.Id] {
+				next = dep.Id
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		path = append(path, next)
+		if i, ok := visited[next]; ok {
+			path = path[i:]
+			break
+		}
+		visited[next] = len(path) - 1
+		cur = next
+	}
+	return strings.Join(path, " -> ")
+}
+
+// 语义化版本，只比较主版本号、次版本号、修订号，带预发布标识（如1.0.0-beta）的版本小于对应的正式版本
+type semVersion struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseSemVersion(s string) (semVersion, error) {
+	v := semVersion{}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = s[i+1:]
+		s = s[:i]
+	}
+	nums := strings.Split(s, ".")
+	if s == "" || len(nums) > 3 {
+		return v, fmt.Errorf("版本号%q格式错误", s)
+	}
+	for i, n := range nums {
+		num, err := strconv.Atoi(n)
+		if err != nil || num < 0 {
+			return v, fmt.Errorf("版本号%q格式错误", s)
+		}
+		v.parts[i] = num
+	}
+	return v, nil
+}
+
+func (v semVersion) compare(o semVersion) int {
+	for i := range v.parts {
+		if v.parts[i] != o.parts[i] {
+			if v.parts[i] < o.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == o.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case o.prerelease == "":
+		return -1
+	case v.prerelease < o.prerelease:
+		return -1
+	}
+	return 1
+}
+
+// 判断版本是否满足约束。约束中“||”分隔的任一组满足即可，每组内以逗号或空格分隔的条件需全部满足
+func matchVersionConstraint(version, constraint string) (bool, error) {
+	v, err := parseSemVersion(version)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range strings.Split(constraint, "||") {
+		all := true
+		conds := splitVersionConditions(group)
+		for _, cond := range conds {
+			ok, err := matchVersionCondition(v, cond)
+			if err != nil {
+				return false, err
+			}
+			all = all && ok
+		}
+		if all && len(conds) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 按逗号或空白拆分条件，单独的运算符与其后的版本号合并，如“>= 1.0”
+func splitVersionConditions(group string) []string {
+	fields := strings.FieldsFunc(group, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	conds := []string{}
+	op := ""
+	for _, f := range fields {
+		if strings.Trim(f, "<>=!^~") == "" {
+			op += f
+			continue
+		}
+		conds = append(conds, op+f)
+		op = ""
+	}
+	// 末尾多余的运算符留给matchVersionCondition报错
+	if op != "" {
+		conds = append(conds, op)
+	}
+	return conds
+}
+
+func matchVersionCondition(v semVersion, cond string) (bool, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(cond, prefix) {
+			op = prefix
+			break
+		}
+	}
+	target := strings.TrimSpace(cond[len(op):])
+
+	// 通配符，如1.x、1.2.*
+	if op == "" || op == "=" {
+		parts := strings.Split(strings.TrimPrefix(target, "v"), ".")
+		for i, p := range parts {
+			if p == "x" || p == "X" || p == "*" {
+				if i == 0 {
+					return true, nil
+				}
+				prefix, err := parseSemVersion(strings.Join(parts[:i], "."))
+				if err != nil {
+					return false, err
+				}
+				for j := 0; j < i; j++ {
+					if v.parts[j] != prefix.parts[j] {
+						return false, nil
+					}
+				}
+				return true, nil
+			}
+		}
+	}
+
+	t, err := parseSemVersion(target)
+	if err != nil {
+		return false, err
+	}
+	c := v.compare(t)
+	switch op {
+	case ">=":
+		return c >= 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case "<":
+		return c < 0, nil
+	case "!=":
+		return c != 0, nil
+	case "^":
+		// 不改变最左侧的非零版本号，如^1.2.3表示[1.2.3, 2.0.0)，^0.2.3表示[0.2.3, 0.3.0)
+		if c < 0 {
+			return false, nil
+		}
+		for i := range t.parts {
+			if t.parts[i] != 0 || i == len(t.parts)-1 {
+				for j := 0; j <= i; j++ {
+					if v.parts[j] != t.parts[j] {
+						return false, nil
+					}
+				}
+				return true, nil
+			}
+		}
+		return true, nil
+	case "~":
+		// 只允许修订号变化，如~1.2.3表示[1.2.3, 1.3.0)
+		return c >= 0 && v.parts[0] == t.parts[0] && v.parts[1] == t.parts[1], nil
+	}
+	return c == 0, nil
+}
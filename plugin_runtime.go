@@ -0,0 +1,188 @@
+package gonebot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Engine上已加载的插件
+type pluginHubRegistry struct {
+	hubs map[string]*PluginHub
+	mu   sync.RWMutex
+}
+
+func (r *pluginHubRegistry) add(id string, hub *PluginHub) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hubs == nil {
+		r.hubs = make(map[string]*PluginHub)
+	}
+	r.hubs[id] = hub
+}
+
+func (r *pluginHubRegistry) get(id string) *PluginHub {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.hubs[id]
+}
+
+// 已加载的插件ID，按字典序排列
+func (r *pluginHubRegistry) ids() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.hubs))
+	for id := range r.hubs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// 插件是否已加载且处于启用状态
+func (engine *Engine) IsPluginEnabled(id string) bool {
+	hub := engine.plugins.get(id)
+	return hub != nil && hub.IsEnabled()
+}
+
+// 已加载的插件（包括被禁用的），按ID排序
+func (engine *Engine) LoadedPlugins() []Plugin {
+	ids := engine.plugins.ids()
+	ret := make([]Plugin, 0, len(ids))
+	for _, id := range ids {
+		ret = append(ret, engine.plugins.get(id).plugin)
+	}
+	return ret
+}
+
+// 在运行时禁用插件：移除插件的所有Handler，结束插件中正在等待的WaitForNextEvent，
+// 暂停插件的定时任务，执行OnUnload注册的清理函数，插件实现了Unloadable时调用其Unload。
+//
+// 依赖该插件的其他插件会先被禁用。插件已被禁用时不做任何事。
+// 启用与禁用依次进行，不能在插件的生命周期钩子、Init、Unload中调用，否则会一直等待
+func (engine *Engine) DisablePlugin(id string) error {
+	engine.pluginToggleLock.Lock()
+	defer engine.pluginToggleLock.Unlock()
+	return engine.disablePlugin(id)
+}
+
+// 禁用插件，需持有pluginToggleLock
+func (engine *Engine) disablePlugin(id string) error {
+	if defaultPluginManager.GetPluginById(id) == nil {
+		return fmt.Errorf("%w: %s", ErrPluginNotFound, id)
+	}
+	hub := engine.plugins.get(id)
+	if hub == nil || !hub.IsEnabled() {
+		return nil
+	}
+
+	for _, dependent := range engine.enabledDependents(id) {
+		log.Infof("插件%s依赖于%s，一并禁用", dependent, id)
+		if err := engine.disablePlugin(dependent); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("正在为插件%s运行PluginWillUnload钩子", id)
+	GlobalHooks.firePluginHook(pluginLifecycleHook_PluginWillUnload, hub)
+
+	hub.mu.Lock()
+	hub.enabled = false
+	hub.detach()
+	hub.handler.mu.Lock()
+	close(hub.handler.unloadSignal)
+	hub.handler.mu.Unlock()
+	cleanups := hub.onUnload
+	hub.onUnload = nil
+	jobs := hub.listJobs()
+	hub.mu.Unlock()
+
+	for _, job := range jobs {
+		job.pause()
+	}
+	for _, f := range cleanups {
+		f()
+	}
+	if p, ok := hub.plugin.(Unloadable); ok {
+		p.Unload(hub)
+	}
+
+	log.Debugf("正在为插件%s运行PluginUnloaded钩子", id)
+	GlobalHooks.firePluginHook(pluginLifecycleHook_PluginUnloaded, hub)
+	log.Infof("插件%s已禁用", id)
+	return nil
+}
+
+// 在运行时启用插件。被DisablePlugin禁用的插件会在原来的位置重新添加Handler并再次调用Init；
+// 启动时未加载的插件（如在配置中被禁用）会在此时初始化。
+//
+// 插件必需的依赖未启用时返回错误。插件已启用时不做任何事
+func (engine *Engine) EnablePlugin(id string) error {
+	engine.pluginToggleLock.Lock()
+	defer engine.pluginToggleLock.Unlock()
+
+	plugin := defaultPluginManager.GetPluginById(id)
+	if plugin == nil {
+		return fmt.Errorf("%w: %s", ErrPluginNotFound, id)
+	}
+	for _, dep := range plugin.GetPluginInfo().Dependencies {
+		if !dep.Optional && !engine.IsPluginEnabled(dep.Id) {
+			return fmt.Errorf("%w: %s", ErrPluginDependencyDisabled, dep.Id)
+		}
+	}
+
+	hub := engine.plugins.get(id)
+	if hub == nil {
+		if err := defaultPluginManager.loadPluginConfig(engine, id); err != nil {
+			return err
+		}
+		defaultPluginManager.loadPlugin(engine, id)
+		return nil
+	}
+	if hub.IsEnabled() {
+		return nil
+	}
+
+	log.Debugf("正在为插件%s运行PluginWillLoad钩子", id)
+	GlobalHooks.firePluginHook(pluginLifecycleHook_PluginWillLoad, hub)
+
+	// 旧的Handler、清理函数都已随禁用失效，重新初始化。Init中添加的定时任务会被再次添加，
+	// 先取消旧的；其他时候添加的任务继续执行
+	hub.mu.Lock()
+	hub.attach(hub.handler.seq)
+	hub.enabled = true
+	jobs := hub.listJobs()
+	hub.mu.Unlock()
+	for _, job := range jobs {
+		if job.fromInit {
+			job.Cancel()
+		} else {
+			job.resume()
+		}
+	}
+	hub.init()
+
+	log.Debugf("正在为插件%s运行PluginLoaded钩子", id)
+	GlobalHooks.firePluginHook(pluginLifecycleHook_PluginLoaded, hub)
+	log.Infof("插件%s已启用", id)
+	return nil
+}
+
+// 已启用的插件中，必需依赖包含id的插件
+func (engine *Engine) enabledDependents(id string) []string {
+	ret := []string{}
+	for _, other := range engine.plugins.ids() {
+		if !engine.IsPluginEnabled(other) {
+			continue
+		}
+		for _, dep := range defaultPluginManager.GetPluginById(other).GetPluginInfo().Dependencies {
+			if dep.Id == id && !dep.Optional {
+				ret = append(ret, other)
+				break
+			}
+		}
+	}
+	return ret
+}
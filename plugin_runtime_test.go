@@ -0,0 +1,270 @@
+package gonebot
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type unloadablePlugin struct {
+	name     string
+	deps     []PluginDependency
+	handled  int32
+	unloaded int32
+	waitDone chan I_Event
+}
+
+func (p *unloadablePlugin) Init(hub *PluginHub) {
+	hub.NewHandler(EventName_PrivateMessage).Handle(func(ctx *Context) {
+		atomic.AddInt32(&p.handled, 1)
+		if p.waitDone != nil {
+			p.waitDone <- ctx.WaitForNextEvent(10)
+		}
+	})
+}
+
+func (p *unloadablePlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: p.name, Author: "t", Version: "1.0.0", Dependencies: p.deps}
+}
+
+func (p *unloadablePlugin) Unload(hub *PluginHub) {
+	atomic.AddInt32(&p.unloaded, 1)
+}
+
+func Test_DisableEnablePlugin(t *testing.T) {
+	base := &unloadablePlugin{name: "base", waitDone: make(chan I_Event, 1)}
+	dep := &unloadablePlugin{name: "dep", deps: []PluginDependency{{Id: "base@t"}}}
+	RegisterPlugin(base, nil)
+	RegisterPlugin(dep, nil)
+	defer func() {
+		delete(defaultPluginManager.plugins, "base@t")
+		delete(defaultPluginManager.plugins, "dep@t")
+	}()
+
+	engine := NewEngineWithProvider(&BaseConfig{}, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	dispatch := func() {
+		ev := &PrivateMessageEvent{}
+		ev.PostType = PostType_MessageEvent
+		ev.MessageType = "private"
+		ev.EventName = EventName_PrivateMessage
+		ev.Message = MsgPrint("hi")
+		engine.handleEvent(newContext(ev, engine))
+	}
+
+	// base中的Handler在等待下一个事件，禁用后应立即结束
+	go dispatch()
+	time.Sleep(20 * time.Millisecond)
+	var cleaned int32
+	engine.plugins.get("base@t").OnUnload(func() { atomic.AddInt32(&cleaned, 1) })
+	if err := engine.DisablePlugin("base@t"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-base.waitDone:
+		if ev != nil {
+			t.Error("禁用插件后等待应返回nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("禁用插件后等待未结束")
+	}
+	if engine.IsPluginEnabled("dep@t") || atomic.LoadInt32(&dep.unloaded) != 1 {
+		t.Error("依赖被禁用的插件应一并禁用")
+	}
+	if atomic.LoadInt32(&base.unloaded) != 1 || atomic.LoadInt32(&cleaned) != 1 {
+		t.Error("禁用插件时应调用Unload与清理函数")
+	}
+
+	handled := atomic.LoadInt32(&base.handled)
+	dispatch()
+	if atomic.LoadInt32(&base.handled) != handled {
+		t.Error("禁用的插件不应处理事件")
+	}
+
+	if err := engine.EnablePlugin("dep@t"); !errors.Is(err, ErrPluginDependencyDisabled) {
+		t.Errorf("依赖未启用时应返回错误，实际为%v", err)
+	}
+	base.waitDone = nil
+	if err := engine.EnablePlugin("base@t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.EnablePlugin("dep@t"); err != nil {
+		t.Fatal(err)
+	}
+	dispatch()
+	if atomic.LoadInt32(&base.handled) != handled+1 {
+		t.Error("重新启用的插件应处理事件")
+	}
+	if err := engine.DisablePlugin("none@t"); !errors.Is(err, ErrPluginNotFound) {
+		t.Errorf("插件不存在时应返回错误，实际为%v", err)
+	}
+}
+
+type schedulingPlugin struct {
+	handled int32
+	ran     chan struct{}
+}
+
+func (p *schedulingPlugin) Init(hub *PluginHub) {
+	hub.NewHandler(EventName_PrivateMessage).Handle(func(ctx *Context) {
+		atomic.AddInt32(&p.handled, 1)
+	})
+	hub.Schedule("@every 1m", func(bot *Bot) { p.ran <- struct{}{} }, nil)
+}
+
+func (p *schedulingPlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: "scheduling", Author: "t", Version: "1.0.0"}
+}
+
+// 不做任何事的插件，用于占位
+type idlePlugin struct {
+	name string
+}
+
+func (p *idlePlugin) Init(hub *PluginHub) {}
+
+func (p *idlePlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: p.name, Author: "t", Version: "1.0.0"}
+}
+
+func Test_ReenablePlugin(t *testing.T) {
+	plugin := &schedulingPlugin{ran: make(chan struct{}, 10)}
+	RegisterPlugin(&idlePlugin{name: "a"}, nil)
+	RegisterPlugin(plugin, nil)
+	RegisterPlugin(&idlePlugin{name: "z"}, nil)
+	defer func() {
+		delete(defaultPluginManager.plugins, "a@t")
+		delete(defaultPluginManager.plugins, "scheduling@t")
+		delete(defaultPluginManager.plugins, "z@t")
+	}()
+
+	engine := NewEngineWithProvider(&BaseConfig{}, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	defer engine.scheduler.stop()
+	clock := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	engine.SetClock(clock)
+	position := func() int {
+		hub := engine.plugins.get("scheduling@t")
+		engine.mu.RLock()
+		defer engine.mu.RUnlock()
+		for i, h := range engine.subHandlers[EventName_AllEvent] {
+			if h == hub.handler {
+				return i
+			}
+		}
+		return -1
+	}
+	before := position()
+	if before <= 0 || before == len(engine.subHandlers[EventName_AllEvent])-1 {
+		t.Fatalf("插件的Handler应排在中间，实际为%d", before)
+	}
+
+	if err := engine.DisablePlugin("scheduling@t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.EnablePlugin("scheduling@t"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Init再次执行，定时任务与Handler都重新注册
+	clock.advance(t, time.Minute)
+	if n := countRuns(plugin.ran, 50*time.Millisecond); n != 1 {
+		t.Errorf("重新启用后定时任务应执行1次，实际执行%d次", n)
+	}
+	ev := &PrivateMessageEvent{}
+	ev.PostType = PostType_MessageEvent
+	ev.MessageType = "private"
+	ev.EventName = EventName_PrivateMessage
+	ev.Message = MsgPrint("hi")
+	engine.handleEvent(newContext(ev, engine))
+	if atomic.LoadInt32(&plugin.handled) != 1 {
+		t.Error("重新启用的插件应处理事件")
+	}
+	hub := engine.plugins.get("scheduling@t")
+	hub.mu.Lock()
+	jobs := len(hub.jobs)
+	hub.mu.Unlock()
+	if jobs != 1 {
+		t.Errorf("Init中添加的任务应在重新启用时替换，实际有%d个", jobs)
+	}
+	if after := position(); after != before {
+		t.Errorf("重新启用后Handler应在原来的位置%d，实际为%d", before, after)
+	}
+}
+
+func Test_ToggleConcurrently(t *testing.T) {
+	plugin := &unloadablePlugin{name: "toggle"}
+	RegisterPlugin(plugin, nil)
+	defer delete(defaultPluginManager.plugins, "toggle@t")
+
+	engine := NewEngineWithProvider(&BaseConfig{}, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	cancel := GlobalHooks.PluginWillUnload(func(hub *PluginHub) { time.Sleep(20 * time.Millisecond) })
+	defer cancel()
+	count := func() int {
+		hub := engine.plugins.get("toggle@t")
+		n := 0
+		for _, h := range engine.subHandlers[EventName_AllEvent] {
+			if h.hub == hub {
+				n++
+			}
+		}
+		return n
+	}
+
+	// 同时禁用两次，只应卸载一次
+	run := func(f func(string) error) {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := f("toggle@t"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	run(engine.DisablePlugin)
+	if n := atomic.LoadInt32(&plugin.unloaded); n != 1 || count() != 0 {
+		t.Errorf("应卸载1次，实际%d次，还剩%d个Handler", n, count())
+	}
+
+	// 同时启用两次，只应添加一次Handler
+	run(engine.EnablePlugin)
+	if n := count(); n != 1 {
+		t.Errorf("应有1个Handler，实际为%d个", n)
+	}
+}
+
+func Test_LoadedPlugins(t *testing.T) {
+	RegisterPlugin(&idlePlugin{name: "loaded"}, nil)
+	RegisterPlugin(&idlePlugin{name: "disabled"}, nil)
+	defer func() {
+		delete(defaultPluginManager.plugins, "loaded@t")
+		delete(defaultPluginManager.plugins, "disabled@t")
+	}()
+
+	// 配置中禁用的插件不会被加载
+	cfg := &BaseConfig{}
+	cfg.Plugin.Enable = map[string]bool{"disabled@t": false}
+	engine := NewEngineWithProvider(cfg, &recordingProvider{})
+	defer engine.heartbeat.stop()
+
+	ids := []string{}
+	for _, p := range engine.LoadedPlugins() {
+		ids = append(ids, GetPluginId(p))
+	}
+	if len(ids) != 1 || ids[0] != "loaded@t" {
+		t.Errorf("只应返回已加载的插件，实际为%v", ids)
+	}
+
+	// 运行时禁用的插件仍然是已加载的
+	engine.DisablePlugin("loaded@t")
+	if n := len(engine.LoadedPlugins()); n != 1 {
+		t.Errorf("运行时禁用的插件仍应返回，实际返回%d个", n)
+	}
+}
@@ -0,0 +1,328 @@
+package gonebot
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 插件熔断的配置。插件的处理函数在一段时间内多次panic或超时，就暂停处理该插件的事件
+type CircuitBreakerConfig struct {
+	Threshold      int `yaml:"threshold"`       // 窗口内panic与超时的次数达到多少时熔断，默认5，小于0时不熔断
+	Window         int `yaml:"window"`          // 统计的窗口，单位秒，默认60
+	Cooldown       int `yaml:"cooldown"`        // 熔断后多久自动恢复，单位秒，默认300
+	HandlerTimeout int `yaml:"handler_timeout"` // 处理函数执行多久视为超时，单位秒，默认30，小于0时不检测。等待用户回复的时间不计入
+}
+
+func (cfg CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = 5
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 60
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 300
+	}
+	if cfg.HandlerTimeout == 0 {
+		cfg.HandlerTimeout = 30
+	}
+	return cfg
+}
+
+// HandlerTimeout的单位，测试时可以缩短
+var handlerTimeoutUnit = time.Second
+
+// 插件的运行统计
+type PluginStats struct {
+	Handled    int64         // 处理函数被调用的次数
+	Panics     int64         // 处理函数panic的次数
+	Timeouts   int64         // 处理函数超时的次数
+	AvgLatency time.Duration // 处理函数的平均耗时，不含等待用户回复的时间
+	ApiCalls   int64         // 调用API的次数
+	ApiErrors  int64         // 调用API失败的次数
+
+	Tripped      bool      // 是否处于熔断中
+	TrippedUntil time.Time // 熔断自动恢复的时间
+}
+
+type pluginStats struct {
+	handled      atomic.Int64
+	panics       atomic.Int64
+	timeouts     atomic.Int64
+	totalLatency atomic.Int64
+	apiCalls     atomic.Int64
+	apiErrors    atomic.Int64
+}
+
+func (s *pluginStats) recordApiCall(err error) {
+	s.apiCalls.Add(1)
+	if err != nil {
+		s.apiErrors.Add(1)
+	}
+}
+
+// 熔断器，记录窗口内失败的时间
+type circuitBreaker struct {
+	failures     []time.Time
+	trippedUntil time.Time
+	mu           sync.Mutex
+}
+
+// 记录一次失败，返回是否因此熔断
+func (b *circuitBreaker) recordFailure(now time.Time, cfg CircuitBreakerConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cfg.Threshold < 0 || now.Before(b.trippedUntil) {
+		return false
+	}
+
+	window := time.Duration(cfg.Window) * time.Second
+	kept := b.failures[:0]
+	for _, t := range b.failures {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	b.failures = append(kept, now)
+	if len(b.failures) < cfg.Threshold {
+		return false
+	}
+	b.failures = nil
+	b.trippedUntil = now.Add(time.Duration(cfg.Cooldown) * time.Second)
+	return true
+}
+
+func (b *circuitBreaker) isTripped(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Before(b.trippedUntil)
+}
+
+func (b *circuitBreaker) reset() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tripped := !b.trippedUntil.IsZero()
+	b.trippedUntil = time.Time{}
+	b.failures = nil
+	return tripped
+}
+
+// 插件的运行统计
+func (p *PluginHub) Stats() PluginStats {
+	ret := PluginStats{
+		Handled:   p.stats.handled.Load(),
+		Panics:    p.stats.panics.Load(),
+		Timeouts:  p.stats.timeouts.Load(),
+		ApiCalls:  p.stats.apiCalls.Load(),
+		ApiErrors: p.stats.apiErrors.Load(),
+	}
+	if ret.Handled > 0 {
+		ret.AvgLatency = time.Duration(p.stats.totalLatency.Load() / ret.Handled)
+	}
+	p.breaker.mu.Lock()
+	ret.TrippedUntil = p.breaker.trippedUntil
+	p.breaker.mu.Unlock()
+	ret.Tripped = p.engine.now().Before(ret.TrippedUntil)
+	return ret
+}
+
+// 插件的运行统计
+func (engine *Engine) PluginStats(id string) (PluginStats, error) {
+	hub := engine.plugins.get(id)
+	if hub == nil {
+		return PluginStats{}, fmt.Errorf("%w: %s", ErrPluginNotFound, id)
+	}
+	return hub.Stats(), nil
+}
+
+// 解除插件的熔断
+func (engine *Engine) ResumePlugin(id string) error {
+	hub := engine.plugins.get(id)
+	if hub == nil {
+		return fmt.Errorf("%w: %s", ErrPluginNotFound, id)
+	}
+	if hub.breaker.reset() {
+		log.Infof("插件%s已解除熔断", id)
+	}
+	return nil
+}
+
+func (engine *Engine) now() time.Time {
+	return engine.scheduler.getClock().Now()
+}
+
+// 插件Hub的中间件，插件熔断时不处理
+func pluginBreakerMiddleware(hub *PluginHub) Middleware {
+	return func(ctx *Context) bool {
+		return !hub.breaker.isTripped(hub.engine.now())
+	}
+}
+
+// 记录一次panic或超时，达到阈值时熔断并通知超级用户
+func (p *PluginHub) recordFailure(reason string) {
+	cfg := p.engine.GetConfig().GetBaseConfig().CircuitBreaker.withDefaults()
+	if !p.breaker.recordFailure(p.engine.now(), cfg) {
+		return
+	}
+	msg := fmt.Sprintf("插件%s在%d秒内%s等错误达到%d次，已暂停%d秒。可发送“plugin resume %s”提前恢复",
+		p.GetPluginId(), cfg.Window, reason, cfg.Threshold, cfg.Cooldown, p.GetPluginId())
+	log.Warn(msg)
+	go func() {
+		for _, su := range p.engine.GetConfig().GetBaseConfig().Superuser {
+			if _, err := p.engine.bot.SendPrivateMsg(su, MsgPrint(msg), false); err != nil {
+				log.Errorf("通知超级用户%d失败: %s", su, err)
+			}
+		}
+	}()
+}
+
+// 执行处理函数。属于插件的处理函数会计入插件的统计，panic会被恢复
+func (h *Handler) callHandleFunc(ctx *Context) {
+	hub := h.pluginHub()
+	if hub == nil {
+		h.handleFunc(ctx)
+		return
+	}
+
+	// 执行期间ctx.Bot调用的API计入插件的统计。ctx.Next可能执行其他插件的处理函数，结束后复原
+	bot, timer := ctx.Bot, ctx.handlerTimer
+	if bot != nil && bot.botState != nil {
+		ctx.Bot = bot.forPlugin(&hub.stats)
+	}
+	cfg := hub.engine.GetConfig().GetBaseConfig().CircuitBreaker.withDefaults()
+	ctx.handlerTimer = newHandlerTimer(time.Duration(cfg.HandlerTimeout)*handlerTimeoutUnit, func() {
+		hub.stats.timeouts.Add(1)
+		log.Warnf("插件%s的处理函数执行超过%d秒", hub.GetPluginId(), cfg.HandlerTimeout)
+		hub.recordFailure("超时")
+	})
+
+	defer func() {
+		ctx.handlerTimer.stop()
+		hub.stats.handled.Add(1)
+		hub.stats.totalLatency.Add(int64(ctx.handlerTimer.elapsed()))
+		ctx.Bot, ctx.handlerTimer = bot, timer
+
+		if err := recover(); err != nil {
+			hub.stats.panics.Add(1)
+			log.Errorf("插件%s的处理函数出错：%v\n%s", hub.GetPluginId(), err, debug.Stack())
+			hub.recordFailure("panic")
+		}
+	}()
+	h.handleFunc(ctx)
+}
+
+// 所属的插件，不属于任何插件时返回nil
+func (h *Handler) pluginHub() *PluginHub {
+	for cur := h; cur != nil; cur = cur.parent {
+		if cur.hub != nil {
+			return cur.hub
+		}
+	}
+	return nil
+}
+
+// 处理函数的超时计时，等待用户回复的时间不计入
+type handlerTimer struct {
+	timer     *time.Timer // 不检测超时时为nil
+	started   time.Time
+	remaining time.Duration
+	waited    time.Duration // 等待用户回复的总时间
+	waitStart time.Time
+	paused    bool
+	mu        sync.Mutex
+}
+
+func newHandlerTimer(timeout time.Duration, onTimeout func()) *handlerTimer {
+	t := &handlerTimer{started: time.Now(), remaining: timeout}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, onTimeout)
+	}
+	return t
+}
+
+// 开始等待用户回复
+func (t *handlerTimer) pause() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.waitStart = now
+	// 已经超时的不再恢复计时
+	if t.timer != nil && t.timer.Stop() {
+		t.paused = true
+		t.remaining -= now.Sub(t.started) - t.waited
+	}
+}
+
+func (t *handlerTimer) resume() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.waited += time.Since(t.waitStart)
+	if t.paused {
+		t.paused = false
+		t.timer.Reset(t.remaining)
+	}
+}
+
+func (t *handlerTimer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// 不含等待时间的耗时
+func (t *handlerTimer) elapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Since(t.started) - t.waited
+}
+
+// 处理“plugin stats [插件名]”、“plugin resume 插件名”
+func (engine *Engine) handlePluginStatsCommand(ctx *Context, args []string) {
+	if args[0] == "stats" && len(args) == 1 {
+		ctx.Reply(engine.describePluginStats(engine.plugins.ids()))
+		return
+	}
+	if len(args) < 2 {
+		ctx.Reply("用法：\nplugin stats [插件名] 查看插件运行统计\nplugin resume 插件名 解除插件的熔断")
+		return
+	}
+	id, err := engine.findPluginByName(args[1])
+	if err != nil {
+		ctx.Reply(err.Error())
+		return
+	}
+	if args[0] == "stats" {
+		ctx.Reply(engine.describePluginStats([]string{id}))
+		return
+	}
+	engine.ResumePlugin(id)
+	ctx.Reply(fmt.Sprintf("已恢复插件%s", id))
+}
+
+func (engine *Engine) describePluginStats(ids []string) string {
+	lines := []string{"插件运行统计："}
+	for _, id := range ids {
+		s := engine.plugins.get(id).Stats()
+		line := fmt.Sprintf("%s 处理%d次 平均%s panic%d次 超时%d次 API%d次（失败%d次）",
+			id, s.Handled, s.AvgLatency.Round(time.Millisecond), s.Panics, s.Timeouts, s.ApiCalls, s.ApiErrors)
+		if s.Tripped {
+			line += fmt.Sprintf(" [熔断至%s]", s.TrippedUntil.Format(time.TimeOnly))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,189 @@
+package gonebot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+type panicPlugin struct{}
+
+func (p *panicPlugin) Init(hub *PluginHub) {
+	hub.NewHandler(EventName_GroupMessage).Use(Keyword("炸")).Handle(func(ctx *Context) {
+		ctx.Reply("要炸了")
+		panic("炸了")
+	})
+}
+
+func (p *panicPlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: "Boom", Author: "t", Version: "1.0.0"}
+}
+
+func Test_PluginCircuitBreaker(t *testing.T) {
+	RegisterPlugin(&panicPlugin{}, nil)
+	defer delete(defaultPluginManager.plugins, "Boom@t")
+
+	cfg := &BaseConfig{CmdPrefix: []string{"!"}, Superuser: []int64{9}}
+	cfg.CircuitBreaker = CircuitBreakerConfig{Threshold: 3, Cooldown: 60}
+	provider := &recordingProvider{}
+	engine := NewEngineWithProvider(cfg, provider)
+	engine.heartbeat.stop()
+	clock := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	engine.SetClock(clock)
+
+	send := func(raw string) {
+		engine.handleEvent(newContext(ConvertJsonObjectToEvent(gjson.Parse(raw)), engine))
+	}
+	boom := func(times int) {
+		for i := 0; i < times; i++ {
+			send(`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 2, "group_id": 100, "message": "炸", "sender": {"user_id": 2}}`)
+		}
+	}
+	stats := func() PluginStats {
+		s, err := engine.PluginStats("Boom@t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// panic不影响Engine，达到阈值后熔断，不再处理
+	boom(4)
+	s := stats()
+	if s.Handled != 3 || s.Panics != 3 || s.ApiCalls != 3 || s.ApiErrors != 0 || !s.Tripped {
+		t.Errorf("统计有误：%+v", s)
+	}
+	notified := false
+	for i := 0; i < 100 && !notified; i++ {
+		for _, route := range provider.routes() {
+			notified = notified || route == "send_private_msg"
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !notified {
+		t.Error("熔断时应通知超级用户")
+	}
+
+	// 超级用户手动恢复
+	resume := func(userId int64) {
+		send(fmt.Sprintf(`{"post_type": "message", "message_type": "private", "self_id": 1, "user_id": %d, "message": "!plugin resume boom", "sender": {"user_id": %d}}`, userId, userId))
+	}
+	resume(2)
+	if !stats().Tripped {
+		t.Error("非超级用户不能恢复插件")
+	}
+	resume(9)
+	if stats().Tripped {
+		t.Error("超级用户应能恢复插件")
+	}
+
+	// 冷却结束后自动恢复
+	boom(3)
+	if !stats().Tripped {
+		t.Error("应再次熔断")
+	}
+	clock.mu.Lock()
+	clock.now = clock.now.Add(time.Minute)
+	clock.mu.Unlock()
+	boom(1)
+	if s := stats(); s.Tripped || s.Handled != 7 {
+		t.Errorf("冷却结束后应自动恢复：%+v", s)
+	}
+}
+
+func Test_ScheduledJobCircuitBreaker(t *testing.T) {
+	RegisterPlugin(&idlePlugin{name: "jobboom"}, nil)
+	defer delete(defaultPluginManager.plugins, "jobboom@t")
+
+	cfg := &BaseConfig{}
+	cfg.CircuitBreaker = CircuitBreakerConfig{Threshold: 2, Window: 600, Cooldown: 600}
+	engine := NewEngineWithProvider(cfg, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	defer engine.scheduler.stop()
+	clock := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	engine.SetClock(clock)
+	hub := engine.plugins.get("jobboom@t")
+
+	ran := make(chan struct{}, 10)
+	hub.Schedule("@every 1m", func(bot *Bot) {
+		ran <- struct{}{}
+		panic("炸了")
+	}, nil)
+	for i := 0; i < 2; i++ {
+		clock.advance(t, time.Minute)
+		countRuns(ran, 50*time.Millisecond)
+	}
+	if s := hub.Stats(); s.Panics != 2 || !s.Tripped {
+		t.Errorf("定时任务的panic应计入统计并熔断：%+v", s)
+	}
+
+	// 熔断期间跳过
+	clock.advance(t, time.Minute)
+	if n := countRuns(ran, 50*time.Millisecond); n != 0 {
+		t.Errorf("熔断期间不应执行定时任务，实际执行%d次", n)
+	}
+}
+
+type slowPlugin struct {
+	waited chan I_Event
+}
+
+func (p *slowPlugin) Init(hub *PluginHub) {
+	hub.NewHandler(EventName_PrivateMessage).Use(Keyword("sleep")).Handle(func(ctx *Context) {
+		time.Sleep(150 * time.Millisecond)
+	})
+	hub.NewHandler(EventName_PrivateMessage).Use(Keyword("wait")).Handle(func(ctx *Context) {
+		p.waited <- ctx.WaitForNextEvent(1, Keyword("next"))
+	})
+}
+
+func (p *slowPlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: "slow", Author: "t", Version: "1.0.0"}
+}
+
+func Test_HandlerTimeout(t *testing.T) {
+	unit := handlerTimeoutUnit
+	handlerTimeoutUnit = 20 * time.Millisecond
+	defer func() { handlerTimeoutUnit = unit }()
+	plugin := &slowPlugin{waited: make(chan I_Event, 1)}
+	RegisterPlugin(plugin, nil)
+	defer delete(defaultPluginManager.plugins, "slow@t")
+
+	// 超时为5*20ms
+	cfg := &BaseConfig{}
+	cfg.CircuitBreaker = CircuitBreakerConfig{Threshold: -1, HandlerTimeout: 5}
+	engine := NewEngineWithProvider(cfg, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	send := func(text string) {
+		raw := fmt.Sprintf(`{"post_type": "message", "message_type": "private", "self_id": 1, "user_id": 2, "message": "%s", "sender": {"user_id": 2}}`, text)
+		engine.handleEvent(newContext(ConvertJsonObjectToEvent(gjson.Parse(raw)), engine))
+	}
+	timeouts := func() int64 {
+		s, _ := engine.PluginStats("slow@t")
+		return s.Timeouts
+	}
+
+	send("sleep")
+	if n := timeouts(); n != 1 {
+		t.Errorf("处理函数执行过久应记为超时，实际为%d次", n)
+	}
+
+	// 等待用户回复的时间不计入
+	go send("wait")
+	time.Sleep(150 * time.Millisecond)
+	send("next")
+	select {
+	case ev := <-plugin.waited:
+		if ev == nil {
+			t.Fatal("应等到下一个事件")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待未结束")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := timeouts(); n != 1 {
+		t.Errorf("等待用户回复的时间不应计入超时，实际超时%d次", n)
+	}
+}
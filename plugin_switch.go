@@ -0,0 +1,296 @@
+package gonebot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 按群、按私聊用户开关插件的配置
+type PluginSwitchConfig struct {
+	Command   string `yaml:"command"`    // 管理命令，默认为plugin，如“/plugin off 插件名”。填“-”则不注册命令
+	StoreFile string `yaml:"store_file"` // 保存开关状态的JSON文件，不填则只保存在内存中，重启后丢失
+}
+
+// 插件开关状态的存储。状态为“插件ID -> 范围 -> 是否启用”，范围见GroupScope、UserScope
+type PluginSwitchStore interface {
+	Load() (map[string]map[string]bool, error)
+	Save(states map[string]map[string]bool) error
+}
+
+// 群聊的开关范围
+func GroupScope(groupId int64) string {
+	return fmt.Sprintf("group:%d", groupId)
+}
+
+// 私聊用户的开关范围
+func UserScope(userId int64) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+// 事件所属的开关范围。群相关的事件为群，其余带有UserId的事件为私聊用户，都没有时返回空字符串
+func EventScope(ev I_Event) string {
+	if gid, ok := getInt64EventField(ev, "GroupId"); ok && gid != 0 {
+		return GroupScope(gid)
+	}
+	if uid, ok := getInt64EventField(ev, "UserId"); ok && uid != 0 {
+		return UserScope(uid)
+	}
+	return ""
+}
+
+// 以JSON文件保存开关状态
+type jsonFileSwitchStore struct {
+	path string
+}
+
+func NewJSONFileSwitchStore(path string) PluginSwitchStore {
+	return &jsonFileSwitchStore{path: path}
+}
+
+func (s *jsonFileSwitchStore) Load() (map[string]map[string]bool, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	states := map[string]map[string]bool{}
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (s *jsonFileSwitchStore) Save(states map[string]map[string]bool) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件再替换，写入中途出错时原文件不受影响
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// 各插件在各群、各私聊用户中的开关状态，未设置的默认启用
+type pluginSwitches struct {
+	states map[string]map[string]bool
+	store  PluginSwitchStore
+	mu     sync.RWMutex
+	saveMu sync.Mutex // 保证按顺序修改与保存
+}
+
+func (s *pluginSwitches) isEnabled(pluginId, scope string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	enabled, ok := s.states[pluginId][scope]
+	return !ok || enabled
+}
+
+// 修改开关状态。有存储时先保存，保存成功后才生效
+func (s *pluginSwitches) set(pluginId, scope string, enabled bool) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.RLock()
+	states := make(map[string]map[string]bool, len(s.states)+1)
+	for id, scopes := range s.states {
+		states[id] = scopes
+	}
+	scopes := make(map[string]bool, len(s.states[pluginId])+1)
+	for k, v := range s.states[pluginId] {
+		scopes[k] = v
+	}
+	store := s.store
+	s.mu.RUnlock()
+
+	// 只记录与默认值不同的状态
+	if enabled {
+		delete(scopes, scope)
+	} else {
+		scopes[scope] = false
+	}
+	if len(scopes) == 0 {
+		delete(states, pluginId)
+	} else {
+		states[pluginId] = scopes
+	}
+	if store != nil {
+		if err := store.Save(states); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.states = states
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *pluginSwitches) setStore(store PluginSwitchStore) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	states, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if states == nil {
+		states = make(map[string]map[string]bool)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+	s.states = states
+	return nil
+}
+
+// 设置保存插件开关状态的存储，并从中载入状态
+func (engine *Engine) SetPluginSwitchStore(store PluginSwitchStore) error {
+	return engine.switches.setStore(store)
+}
+
+// 插件在某个群或私聊用户中是否启用
+func (engine *Engine) IsPluginEnabledIn(pluginId, scope string) bool {
+	return engine.switches.isEnabled(pluginId, scope)
+}
+
+// 在某个群或私聊用户中开关插件，状态会写入存储
+func (engine *Engine) SetPluginEnabledIn(pluginId, scope string, enabled bool) error {
+	if defaultPluginManager.GetPluginById(pluginId) == nil {
+		return fmt.Errorf("%w: %s", ErrPluginNotFound, pluginId)
+	}
+	return engine.switches.set(pluginId, scope, enabled)
+}
+
+// 插件Hub的中间件，插件在事件所属的群或私聊中被关闭时不处理
+func pluginSwitchMiddleware(hub *PluginHub) Middleware {
+	return func(ctx *Context) bool {
+		scope := EventScope(ctx.Event)
+		return scope == "" || hub.engine.IsPluginEnabledIn(hub.GetPluginId(), scope)
+	}
+}
+
+// 读取配置，载入开关状态并注册管理命令
+func (engine *Engine) initPluginSwitches() {
+	cfg := engine.GetConfig().GetBaseConfig().PluginSwitch
+	if cfg.StoreFile != "" {
+		if err := engine.SetPluginSwitchStore(NewJSONFileSwitchStore(cfg.StoreFile)); err != nil {
+			log.Errorf("载入插件开关状态失败: %s", err)
+		}
+	}
+
+	if cfg.Command == "-" {
+		return
+	}
+	cmd := cfg.Command
+	if cmd == "" {
+		cmd = "plugin"
+	}
+	engine.NewHandler(EventName_GroupMessage).
+		Use(Command(cmd), FromAdminOrHigher()).
+		Handle(engine.handlePluginSwitchCommand)
+	// 私聊中只能管理自己的私聊
+	engine.NewHandler(EventName_PrivateMessage).
+		Use(Command(cmd)).
+		Handle(engine.handlePluginSwitchCommand)
+}
+
+// 处理“plugin list”、“plugin on 插件名”、“plugin off 插件名”，
+// 以及超级用户的“plugin stats [插件名]”、“plugin resume 插件名”
+func (engine *Engine) handlePluginSwitchCommand(ctx *Context) {
+	args := ctx.GetCommandMatchResult().Args
+	scope := EventScope(ctx.Event)
+	if len(args) == 0 || args[0] == "list" {
+		ctx.Reply(engine.describePluginSwitches(scope))
+		return
+	}
+	if (args[0] == "stats" || args[0] == "resume") && FromSuperuser()(ctx) {
+		engine.handlePluginStatsCommand(ctx, args)
+		return
+	}
+
+	if len(args) < 2 || args[0] != "on" && args[0] != "off" {
+		ctx.Reply("用法：\nplugin list 查看插件\nplugin on 插件名 开启插件\nplugin off 插件名 关闭插件")
+		return
+	}
+	id, err := engine.findPluginByName(args[1])
+	if err != nil {
+		ctx.Reply(err.Error())
+		return
+	}
+	enabled := args[0] == "on"
+	if err = engine.SetPluginEnabledIn(id, scope, enabled); err != nil {
+		log.Errorf("保存插件开关状态失败: %s", err)
+		ctx.Reply("保存失败：", err.Error())
+		return
+	}
+	if enabled {
+		ctx.Reply(fmt.Sprintf("已开启插件%s", id))
+	} else {
+		ctx.Reply(fmt.Sprintf("已关闭插件%s", id))
+	}
+}
+
+// 按ID或插件名查找已加载的插件，插件名不区分大小写
+func (engine *Engine) findPluginByName(name string) (string, error) {
+	matched := []string{}
+	for _, id := range engine.plugins.ids() {
+		if id == name {
+			return id, nil
+		}
+		if strings.EqualFold(defaultPluginManager.GetPluginById(id).GetPluginInfo().Name, name) {
+			matched = append(matched, id)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("没有名为%s的插件", name)
+	case 1:
+		return matched[0], nil
+	}
+	sort.Strings(matched)
+	return "", fmt.Errorf("有多个名为%s的插件，请使用完整的ID：%s", name, strings.Join(matched, "、"))
+}
+
+func (engine *Engine) describePluginSwitches(scope string) string {
+	lines := []string{"插件列表："}
+	for _, id := range engine.plugins.ids() {
+		state := "开"
+		switch {
+		case !engine.IsPluginEnabled(id):
+			state = "全局关闭"
+		case !engine.IsPluginEnabledIn(id, scope):
+			state = "关"
+		}
+		line := fmt.Sprintf("[%s] %s", state, id)
+		if desc := defaultPluginManager.GetPluginById(id).GetPluginInfo().Description; desc != "" {
+			line += " " + desc
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,129 @@
+package gonebot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+type switchPlugin struct {
+	handled int32
+}
+
+func (p *switchPlugin) Init(hub *PluginHub) {
+	hub.NewHandler(EventName_GroupMessage).Use(Keyword("天气")).Handle(func(ctx *Context) {
+		atomic.AddInt32(&p.handled, 1)
+	})
+}
+
+func (p *switchPlugin) GetPluginInfo() PluginInfo {
+	return PluginInfo{Name: "Weather", Author: "t", Version: "1.0.0"}
+}
+
+func Test_PluginSwitch(t *testing.T) {
+	plugin := &switchPlugin{}
+	RegisterPlugin(plugin, nil)
+	defer delete(defaultPluginManager.plugins, "Weather@t")
+
+	cfg := &BaseConfig{CmdPrefix: []string{"!"}}
+	cfg.PluginSwitch.StoreFile = filepath.Join(t.TempDir(), "switch.json")
+	newEngine := func() *Engine {
+		engine := NewEngineWithProvider(cfg, &recordingProvider{})
+		engine.heartbeat.stop()
+		return engine
+	}
+	groupMsg := func(engine *Engine, groupId int64, role, text string) {
+		raw := fmt.Sprintf(`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 2, "group_id": %d, "message": %q, "sender": {"user_id": 2, "role": %q}}`, groupId, text, role)
+		engine.handleEvent(newContext(ConvertJsonObjectToEvent(gjson.Parse(raw)), engine))
+	}
+
+	engine := newEngine()
+	groupMsg(engine, 100, "member", "!plugin off weather")
+	if !engine.IsPluginEnabledIn("Weather@t", GroupScope(100)) {
+		t.Error("普通群员不能关闭插件")
+	}
+	groupMsg(engine, 100, "admin", "!plugin off weather")
+	groupMsg(engine, 100, "member", "今天天气")
+	groupMsg(engine, 200, "member", "今天天气")
+	if n := atomic.LoadInt32(&plugin.handled); n != 1 {
+		t.Errorf("插件只应处理群200的消息，实际处理了%d次", n)
+	}
+
+	// 重启后状态仍然保留
+	engine = newEngine()
+	if engine.IsPluginEnabledIn("Weather@t", GroupScope(100)) || !engine.IsPluginEnabledIn("Weather@t", GroupScope(200)) {
+		t.Error("开关状态未被保存")
+	}
+	groupMsg(engine, 100, "owner", "!plugin on Weather@t")
+	if !engine.IsPluginEnabledIn("Weather@t", GroupScope(100)) {
+		t.Error("群主应能开启插件")
+	}
+}
+
+// 保存总是失败的存储
+type failingSwitchStore struct{}
+
+func (failingSwitchStore) Load() (map[string]map[string]bool, error) {
+	return nil, nil
+}
+
+func (failingSwitchStore) Save(states map[string]map[string]bool) error {
+	return errors.New("磁盘已满")
+}
+
+func Test_PluginSwitchSave(t *testing.T) {
+	// 保存失败时不修改状态
+	s := &pluginSwitches{}
+	if err := s.setStore(failingSwitchStore{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.set("a@t", GroupScope(1), false); err == nil {
+		t.Error("保存失败时应返回错误")
+	}
+	if !s.isEnabled("a@t", GroupScope(1)) {
+		t.Error("保存失败时不应修改状态")
+	}
+
+	// 写入JSON文件后不留下临时文件
+	dir := t.TempDir()
+	path := filepath.Join(dir, "switch.json")
+	if err := os.WriteFile(path, []byte(`{"a@t": {"group:1": false}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s = &pluginSwitches{}
+	if err := s.setStore(NewJSONFileSwitchStore(path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.set("a@t", GroupScope(2), false); err != nil {
+		t.Fatal(err)
+	}
+	states, err := NewJSONFileSwitchStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states["a@t"]) != 2 || states["a@t"][GroupScope(1)] || states["a@t"][GroupScope(2)] {
+		t.Errorf("保存的状态有误：%v", states)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("不应留下临时文件，目录中有%d个文件", len(entries))
+	}
+
+	// 文件无法写入时保留原状态
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.set("a@t", GroupScope(1), true); err == nil {
+		t.Error("文件无法写入时应返回错误")
+	}
+	if s.isEnabled("a@t", GroupScope(1)) {
+		t.Error("保存失败时不应修改状态")
+	}
+}
@@ -1,7 +1,9 @@
 package gonebot
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -84,3 +86,155 @@ func Test_pluginInit(t *testing.T) {
 	// engine := NewEngine(&cfg)
 	// engine.Run()
 }
+
+func Test_matchVersionConstraint(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"1.2.3", ">=1.2.0", true},
+		{"1.2.3", ">1.2.3", false},
+		{"1.2.3", "^1.0", true},
+		{"2.0.0", "^1.0", false},
+		{"0.3.0", "^0.2.3", false},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.9.0", "1.x", true},
+		{"v1.5.0", ">=1.0, <2.0", true},
+		{"2.1.0", "^1.0 || ^2.0", true},
+		{"1.0.0-beta", ">=1.0.0", false},
+		{"1.2.0", ">= 1.0", true},
+		{"1.5.0", "^1.2, <2", true},
+		{"2.0.0", "^1.2, <2", false},
+		{"1.5.0", "> 1.0 < 1.5", false},
+	}
+	for _, tt := range tests {
+		if got, err := matchVersionConstraint(tt.version, tt.constraint); err != nil || got != tt.want {
+			t.Errorf("%s %s: 应为%v，实际为%v, %v", tt.version, tt.constraint, tt.want, got, err)
+		}
+	}
+	if _, err := matchVersionConstraint("test", ">=1.0"); err == nil {
+		t.Error("无法解析的版本号应返回错误")
+	}
+	if _, err := matchVersionConstraint("1.0.0", "1.0 >="); err == nil {
+		t.Error("缺少版本号的运算符应返回错误")
+	}
+}
+
+type depPlugin struct {
+	info PluginInfo
+}
+
+func (p *depPlugin) Init(hub *PluginHub)       {}
+func (p *depPlugin) GetPluginInfo() PluginInfo { return p.info }
+
+func Test_resolveLoadOrder(t *testing.T) {
+	pm := newPluginManager()
+	register := func(name, version string, deps ...PluginDependency) {
+		pm.RegisterPlugin(&depPlugin{PluginInfo{Name: name, Author: "t", Version: version, Dependencies: deps}}, nil)
+	}
+	register("db", "1.2.0")
+	register("user", "1.0.0", PluginDependency{Id: "db@t", Version: "^1.0"})
+	register("admin", "1.0.0", PluginDependency{Id: "user@t"}, PluginDependency{Id: "log@t", Optional: true})
+	register("log", "0.1.0")
+	register("a", "1.0.0", PluginDependency{Id: "missing@t", Optional: true})
+	register("old", "1.0.0", PluginDependency{Id: "db@t", Version: ">=2.0"})
+	register("lost", "1.0.0", PluginDependency{Id: "missing@t"})
+	register("off", "1.0.0")
+	register("needoff", "1.0.0", PluginDependency{Id: "off@t"})
+	register("x", "1.0.0", PluginDependency{Id: "y@t"})
+	register("y", "1.0.0", PluginDependency{Id: "x@t"})
+
+	order, skipped := pm.resolveLoadOrder(map[string]bool{"off@t": false}, nil)
+	if got := strings.Join(order, ","); got != "a@t,db@t,log@t,user@t,admin@t" {
+		t.Errorf("加载顺序错误：%s", got)
+	}
+	wantErr := map[string]error{
+		"old@t":     ErrPluginDependencyVersion,
+		"lost@t":    ErrPluginDependencyMissing,
+		"needoff@t": ErrPluginDependencyDisabled,
+		"x@t":       ErrPluginDependencyCycle,
+		"y@t":       ErrPluginDependencyCycle,
+	}
+	if len(skipped) != len(wantErr) {
+		t.Errorf("跳过的插件错误：%v", skipped)
+	}
+	for id, want := range wantErr {
+		if !errors.Is(skipped[id], want) {
+			t.Errorf("%s应因%v被跳过，实际为%v", id, want, skipped[id])
+		}
+	}
+}
+
+type testValidatedConfig struct {
+	Mode    string `default:"fast" enum:"fast,slow"`
+	Workers int    `default:"4" min:"1" max:"16"`
+	Token   string `required:"true"`
+	Retry   int
+	Tags    []string `max:"2"`
+}
+
+func (c *testValidatedConfig) Validate() error {
+	if c.Retry > c.Workers {
+		return errors.New("retry不能大于workers")
+	}
+	return nil
+}
+
+func Test_convertConfigMapToStruct_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     PluginConfigMap
+		wantErr []string // 出错的配置项
+	}{
+		{"默认值", PluginConfigMap{"token": "t"}, nil},
+		{"缺少必填项", PluginConfigMap{}, []string{"token"}},
+		{"超出范围", PluginConfigMap{"token": "t", "workers": 20, "tags": []interface{}{"a", "b", "c"}}, []string{"workers", "tags"}},
+		{"不在枚举中", PluginConfigMap{"token": "t", "mode": "medium"}, []string{"mode"}},
+		{"类型错误", PluginConfigMap{"token": 1, "workers": "many", "retry": []interface{}{1}}, []string{"workers", "retry"}},
+		{"Validate", PluginConfigMap{"token": "t", "retry": 5}, []string{""}},
+	}
+	for _, tt := range tests {
+		cfg := testValidatedConfig{}
+		err := convertConfigMapToStruct(&cfg, tt.src)
+		if len(tt.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: 不应出错，实际为%s", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: 应返回ErrInvalidConfig，实际为%v", tt.name, err)
+			continue
+		}
+		fields := []string{}
+		for _, e := range err.(ConfigErrors) {
+			fields = append(fields, e.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.wantErr, ",") {
+			t.Errorf("%s: 出错的配置项应为%v，实际为%v（%s）", tt.name, tt.wantErr, fields, err)
+		}
+	}
+
+	cfg := testValidatedConfig{}
+	convertConfigMapToStruct(&cfg, PluginConfigMap{"token": "t"})
+	if cfg.Mode != "fast" || cfg.Workers != 4 {
+		t.Errorf("默认值未填充：%+v", cfg)
+	}
+}
+
+func Test_ConfigSchema(t *testing.T) {
+	schema := ConfigSchema(testValidatedConfig{})
+	props := schema["properties"].(map[string]interface{})
+	workers := props["workers"].(map[string]interface{})
+	if workers["type"] != "integer" || workers["default"] != 4 || workers["minimum"] != 1.0 || workers["maximum"] != 16.0 {
+		t.Errorf("workers的schema有误：%v", workers)
+	}
+	if mode := props["mode"].(map[string]interface{}); fmt.Sprint(mode["enum"]) != "[fast slow]" {
+		t.Errorf("mode的schema有误：%v", mode)
+	}
+	if fmt.Sprint(schema["required"]) != "[token]" {
+		t.Errorf("required有误：%v", schema["required"])
+	}
+}
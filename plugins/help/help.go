@@ -0,0 +1,190 @@
+// 内置的帮助插件，导入即可使用：
+//
+//	import _ "github.com/liwh011/gonebot/plugins/help"
+package help
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/liwh011/gonebot"
+)
+
+// 插件配置
+type Config struct {
+	Command  string                  `default:"help"`                              // 命令名
+	Mode     gonebot.LongMessageMode `default:"forward" enum:"split,forward,page"` // 插件列表有多页时的发送方式
+	PageSize int                     `default:"10" min:"1"`                        // 每页的插件数
+}
+
+type HelpPlugin struct {
+	cfg *Config // 注册的配置，重新载入后不会更新，请使用config()
+}
+
+func init() {
+	p := &HelpPlugin{cfg: &Config{}}
+	gonebot.RegisterPlugin(p, p.cfg)
+}
+
+func (p *HelpPlugin) GetPluginInfo() gonebot.PluginInfo {
+	return gonebot.PluginInfo{
+		Name:        "help",
+		Author:      "gonebot",
+		Version:     "1.0.0",
+		Description: "查看插件列表与用法",
+		Usage:       "help 查看插件列表\nhelp 插件名 查看插件的用法",
+		Examples:    []string{"help", "help help"},
+		Category:    "系统",
+	}
+}
+
+// 当前的配置，配置文件重新载入后会更新
+func (p *HelpPlugin) config() *Config {
+	if cfg, ok := gonebot.GetPluginConfig(p).(*Config); ok {
+		return cfg
+	}
+	return p.cfg
+}
+
+// 命令名在初始化时确定，修改后需重新启用插件
+func (p *HelpPlugin) Init(hub *gonebot.PluginHub) {
+	hub.NewHandler(gonebot.EventName_Message).
+		Use(gonebot.Command(p.config().Command)).
+		Handle(p.handle)
+}
+
+func (p *HelpPlugin) handle(ctx *gonebot.Context) {
+	cfg := p.config()
+	plugins := visiblePlugins(ctx)
+	args := ctx.GetCommandMatchResult().Args
+	if len(args) == 0 {
+		ctx.ReplyPages(renderIndex(plugins, cfg), &gonebot.LongMessageOptions{Mode: cfg.Mode})
+		return
+	}
+
+	name := strings.Join(args, " ")
+	for _, plugin := range plugins {
+		info := plugin.GetPluginInfo()
+		if strings.EqualFold(info.Name, name) || gonebot.GetPluginId(plugin) == name {
+			ctx.Reply(renderDetail(info))
+			return
+		}
+	}
+	ctx.Reply(fmt.Sprintf("没有名为%s的插件，发送“%s”查看插件列表", name, cfg.Command))
+}
+
+// 当前用户在当前群或私聊中可以使用的插件
+func visiblePlugins(ctx *gonebot.Context) []gonebot.Plugin {
+	var userId int64
+	switch ev := ctx.Event.(type) {
+	case *gonebot.GroupMessageEvent:
+		userId = ev.Sender.UserId
+	case *gonebot.PrivateMessageEvent:
+		userId = ev.Sender.UserId
+	}
+	isSuperuser := ctx.Engine.IsSuperuser(userId)
+	scope := gonebot.EventScope(ctx.Event)
+
+	ret := []gonebot.Plugin{}
+	for _, plugin := range ctx.Engine.LoadedPlugins() {
+		id := gonebot.GetPluginId(plugin)
+		switch plugin.GetPluginInfo().Visibility {
+		case gonebot.PluginVisibility_Hidden:
+			continue
+		case gonebot.PluginVisibility_Superuser:
+			if !isSuperuser {
+				continue
+			}
+		}
+		if !ctx.Engine.IsPluginEnabled(id) || scope != "" && !ctx.Engine.IsPluginEnabledIn(id, scope) {
+			continue
+		}
+		ret = append(ret, plugin)
+	}
+	return ret
+}
+
+// 按分类列出插件，每页PageSize个
+func renderIndex(plugins []gonebot.Plugin, cfg *Config) []gonebot.Message {
+	if len(plugins) == 0 {
+		return []gonebot.Message{gonebot.MsgPrint("没有可用的插件")}
+	}
+
+	byCategory := map[string][]gonebot.PluginInfo{}
+	for _, plugin := range plugins {
+		info := plugin.GetPluginInfo()
+		byCategory[info.Category] = append(byCategory[info.Category], info)
+	}
+	categories := make([]string, 0, len(byCategory))
+	for c := range byCategory {
+		categories = append(categories, c)
+	}
+	// 未分类的放在最后
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i] == "" || categories[j] == "" {
+			return categories[j] == ""
+		}
+		return categories[i] < categories[j]
+	})
+
+	// 每个插件一行，分类的标题放在该分类第一个插件之前
+	lines := []string{}
+	for _, c := range categories {
+		infos := byCategory[c]
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+		title := c
+		if title == "" {
+			title = "其他"
+		}
+		for i, info := range infos {
+			line := "· " + info.Name
+			if info.Description != "" {
+				line += "：" + info.Description
+			}
+			if i == 0 {
+				line = fmt.Sprintf("【%s】\n%s", title, line)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	pages := []gonebot.Message{}
+	for i := 0; i < len(lines); i += cfg.PageSize {
+		end := i + cfg.PageSize
+		if end > len(lines) {
+			end = len(lines)
+		}
+		text := "插件列表：\n" + strings.Join(lines[i:end], "\n")
+		if end == len(lines) {
+			text += fmt.Sprintf("\n发送“%s 插件名”查看插件的用法", cfg.Command)
+		}
+		pages = append(pages, gonebot.MsgPrint(text))
+	}
+	return pages
+}
+
+// 插件的详细信息
+func renderDetail(info gonebot.PluginInfo) string {
+	b := strings.Builder{}
+	b.WriteString(info.Name)
+	if info.Version != "" {
+		b.WriteString(" v" + info.Version)
+	}
+	if info.Author != "" {
+		b.WriteString("（作者：" + info.Author + "）")
+	}
+	if info.Description != "" {
+		b.WriteString("\n" + info.Description)
+	}
+	if info.Usage != "" {
+		b.WriteString("\n\n用法：\n" + info.Usage)
+	}
+	if len(info.Examples) > 0 {
+		b.WriteString("\n\n示例：")
+		for _, ex := range info.Examples {
+			b.WriteString("\n  " + ex)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,150 @@
+package help
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liwh011/gonebot"
+)
+
+type testPlugin struct {
+	info gonebot.PluginInfo
+}
+
+func (p *testPlugin) Init(hub *gonebot.PluginHub) {}
+
+func (p *testPlugin) GetPluginInfo() gonebot.PluginInfo {
+	return p.info
+}
+
+func newTestPlugin(name, category string, visibility gonebot.PluginVisibility) *testPlugin {
+	return &testPlugin{gonebot.PluginInfo{
+		Name:        name,
+		Author:      "t",
+		Version:     "1.0.0",
+		Description: name + "的说明",
+		Category:    category,
+		Visibility:  visibility,
+	}}
+}
+
+func init() {
+	gonebot.RegisterPlugin(newTestPlugin("weather", "工具", gonebot.PluginVisibility_Public), nil)
+	gonebot.RegisterPlugin(newTestPlugin("sign", "", gonebot.PluginVisibility_Public), nil)
+	gonebot.RegisterPlugin(newTestPlugin("secret", "", gonebot.PluginVisibility_Hidden), nil)
+	gonebot.RegisterPlugin(newTestPlugin("admin", "系统", gonebot.PluginVisibility_Superuser), nil)
+}
+
+// 不做任何事的服务提供者
+type nopProvider struct{}
+
+func (nopProvider) Init(cfg gonebot.Config)                {}
+func (nopProvider) Start()                                 {}
+func (nopProvider) Stop()                                  {}
+func (nopProvider) RecieveEvent(ch chan<- gonebot.I_Event) {}
+func (nopProvider) OnEventHandled(ev gonebot.I_Event)      {}
+
+func (nopProvider) Request(route string, data interface{}) (interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func newEngine() *gonebot.Engine {
+	return gonebot.NewEngineWithProvider(&gonebot.BaseConfig{Superuser: []int64{10}}, nopProvider{})
+}
+
+// 某人在某群中发消息时，help中可见的插件名
+func visibleNames(engine *gonebot.Engine, groupId, userId int64) string {
+	ev := &gonebot.GroupMessageEvent{}
+	ev.GroupId = groupId
+	ev.UserId = userId
+	ev.Sender = &gonebot.GroupMessageEventSender{}
+	ev.Sender.UserId = userId
+	ctx := &gonebot.Context{Event: ev, Engine: engine}
+	names := []string{}
+	for _, plugin := range visiblePlugins(ctx) {
+		names = append(names, plugin.GetPluginInfo().Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func Test_VisiblePlugins(t *testing.T) {
+	engine := newEngine()
+
+	// 隐藏的插件总是不显示，仅超级用户可见的插件只对超级用户显示
+	if got, want := visibleNames(engine, 100, 2), "help,sign,weather"; got != want {
+		t.Errorf("普通用户可见的插件应为%s，实际为%s", want, got)
+	}
+	if got, want := visibleNames(engine, 100, 10), "admin,help,sign,weather"; got != want {
+		t.Errorf("超级用户可见的插件应为%s，实际为%s", want, got)
+	}
+
+	// 在某个群中关闭的插件只在该群中不显示
+	if err := engine.SetPluginEnabledIn("sign@t", gonebot.GroupScope(100), false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := visibleNames(engine, 100, 2), "help,weather"; got != want {
+		t.Errorf("群100中可见的插件应为%s，实际为%s", want, got)
+	}
+	if got, want := visibleNames(engine, 200, 2), "help,sign,weather"; got != want {
+		t.Errorf("群200中可见的插件应为%s，实际为%s", want, got)
+	}
+
+	// 全局禁用的插件不显示
+	if err := engine.DisablePlugin("weather@t"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := visibleNames(engine, 200, 2), "help,sign"; got != want {
+		t.Errorf("禁用插件后可见的插件应为%s，实际为%s", want, got)
+	}
+}
+
+func Test_RenderIndex(t *testing.T) {
+	plugins := []gonebot.Plugin{
+		newTestPlugin("sign", "", gonebot.PluginVisibility_Public),
+		newTestPlugin("weather", "工具", gonebot.PluginVisibility_Public),
+		newTestPlugin("admin", "系统", gonebot.PluginVisibility_Public),
+		newTestPlugin("translate", "工具", gonebot.PluginVisibility_Public),
+	}
+	render := func(pageSize int) []string {
+		ret := []string{}
+		for _, page := range renderIndex(plugins, &Config{Command: "help", PageSize: pageSize}) {
+			ret = append(ret, page.ExtractPlainText())
+		}
+		return ret
+	}
+
+	// 分类按名称排列，未分类的放在最后；分类内按插件名排列
+	pages := render(10)
+	want := strings.Join([]string{
+		"插件列表：",
+		"【工具】",
+		"· translate：translate的说明",
+		"· weather：weather的说明",
+		"【系统】",
+		"· admin：admin的说明",
+		"【其他】",
+		"· sign：sign的说明",
+		"发送“help 插件名”查看插件的用法",
+	}, "\n")
+	if len(pages) != 1 || pages[0] != want {
+		t.Errorf("插件列表应为\n%s\n实际为\n%s", want, strings.Join(pages, "\n---\n"))
+	}
+
+	// 每页PageSize个插件，只有最后一页有提示
+	pages = render(3)
+	if len(pages) != 2 {
+		t.Fatalf("应分为2页，实际为%d页", len(pages))
+	}
+	if strings.Contains(pages[0], "发送“help") || !strings.Contains(pages[1], "发送“help") {
+		t.Error("只有最后一页应有提示")
+	}
+	for i, n := range []int{3, 1} {
+		if got := strings.Count(pages[i], "· "); got != n {
+			t.Errorf("第%d页应有%d个插件，实际为%d个", i+1, n, got)
+		}
+	}
+
+	if pages := renderIndex(nil, &Config{PageSize: 10}); len(pages) != 1 || pages[0].ExtractPlainText() != "没有可用的插件" {
+		t.Error("没有插件时应返回一页提示")
+	}
+}
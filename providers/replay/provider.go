@@ -0,0 +1,194 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/liwh011/gonebot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	gonebot.RegisterProvider("replay", &ReplayProvider{})
+}
+
+type ReplayConfig struct {
+	File        string  `yaml:"file"`         // 由Recorder录制的事件文件
+	Speed       float64 `yaml:"speed"`        // 回放速度倍率，1为按录制时的间隔实时回放，2为两倍速，0为不等待
+	CaptureFile string  `yaml:"capture_file"` // 被拦截的API调用写入该文件（JSONL），不填则只输出日志
+}
+
+// 被拦截的API调用
+type CapturedRequest struct {
+	Time   time.Time       `json:"time"`
+	Route  string          `json:"route"`
+	Params json.RawMessage `json:"params"`
+}
+
+// 回放录制的事件。所有API调用都会被拦截而不会真正发送，发送消息类API返回递增的假消息ID
+type ReplayProvider struct {
+	config    ReplayConfig
+	recievers []chan<- gonebot.I_Event
+
+	mu       sync.Mutex
+	captured []CapturedRequest
+	capture  *os.File
+	msgId    int32
+
+	ready     chan struct{} // 有接收者后关闭
+	readyOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// 直接指定配置创建，用于测试等不经过配置文件的场合
+func NewReplayProvider(config ReplayConfig) *ReplayProvider {
+	return &ReplayProvider{config: config}
+}
+
+func (p *ReplayProvider) Init(cfg gonebot.Config) {
+	if mp, ok := cfg.GetBaseConfig().ProviderConfig["replay"]; ok {
+		if err := mp.DecodeTo(&p.config); err != nil {
+			log.Panicf("解析replay配置失败：%s", err)
+		}
+	}
+	if p.config.File == "" {
+		log.Panic("未设置回放文件，请在provider_config.replay.file中指定")
+	}
+	if p.config.CaptureFile != "" {
+		f, err := os.OpenFile(p.config.CaptureFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			log.Panicf("打开capture_file失败：%s", err)
+		}
+		p.capture = f
+	}
+	p.ready = make(chan struct{})
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+}
+
+func (p *ReplayProvider) Start() {
+	defer close(p.done)
+
+	// Engine在Start之后才注册接收者，需等待
+	select {
+	case <-p.ready:
+	case <-p.stop:
+		return
+	}
+
+	f, err := os.Open(p.config.File)
+	if err != nil {
+		log.Errorf("打开回放文件失败：%s", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var last time.Time
+	count := 0
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rcd Record
+		if err := json.Unmarshal(scanner.Bytes(), &rcd); err != nil {
+			log.Warnf("跳过无法解析的记录：%s", err)
+			continue
+		}
+
+		// 按录制时的间隔等待
+		if !last.IsZero() && p.config.Speed > 0 {
+			wait := time.Duration(float64(rcd.Time.Sub(last)) / p.config.Speed)
+			select {
+			case <-time.After(wait):
+			case <-p.stop:
+				return
+			}
+		}
+		last = rcd.Time
+
+		ev, err := gonebot.UnmarshalEvent(rcd.Event)
+		if err != nil {
+			log.Warnf("跳过无法转换的事件：%s", err)
+			continue
+		}
+		for _, ch := range p.getRecievers() {
+			select {
+			case ch <- ev:
+			case <-p.stop:
+				return
+			}
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("读取回放文件失败：%s", err)
+	}
+	log.Infof("回放完毕，共%d个事件", count)
+}
+
+func (p *ReplayProvider) Stop() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.capture != nil {
+		p.capture.Close()
+		p.capture = nil
+	}
+}
+
+// 回放结束时关闭
+func (p *ReplayProvider) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *ReplayProvider) Request(route string, data interface{}) (interface{}, error) {
+	params, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req := CapturedRequest{Time: time.Now(), Route: route, Params: params}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.captured = append(p.captured, req)
+	p.msgId++
+	log.Infof("[回放] 已拦截API调用%s：%s", route, params)
+	if p.capture != nil {
+		line, _ := json.Marshal(req)
+		p.capture.Write(append(line, '\n'))
+	}
+	return map[string]interface{}{"message_id": p.msgId}, nil
+}
+
+// 所有被拦截的API调用
+func (p *ReplayProvider) Requests() []CapturedRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]CapturedRequest{}, p.captured...)
+}
+
+func (p *ReplayProvider) RecieveEvent(ch chan<- gonebot.I_Event) {
+	p.mu.Lock()
+	p.recievers = append(p.recievers, ch)
+	p.mu.Unlock()
+	p.readyOnce.Do(func() { close(p.ready) })
+}
+
+func (p *ReplayProvider) getRecievers() []chan<- gonebot.I_Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]chan<- gonebot.I_Event{}, p.recievers...)
+}
+
+func (p *ReplayProvider) OnEventHandled(ev gonebot.I_Event) {
+}
@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/liwh011/gonebot"
+	log "github.com/sirupsen/logrus"
+)
+
+// 录制文件中的一行，即一个事件
+type Record struct {
+	Time  time.Time       `json:"time"`  // 收到事件的时间
+	Event json.RawMessage `json:"event"` // 事件的JSON，见gonebot.MarshalEvent
+}
+
+// 事件录制器，将Engine收到的事件逐行写入JSONL文件，供回放使用
+type Recorder struct {
+	file *os.File
+	w    *bufio.Writer
+	mu   sync.Mutex
+}
+
+// 创建录制器，事件将追加到path文件末尾
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// 开始录制engine收到的事件，返回的函数用于停止录制。Engine结束时会自动关闭文件
+func (r *Recorder) Attach(engine *gonebot.Engine) (cancel func()) {
+	cancelRecord := engine.Hooks.EventRecieved(func(ev gonebot.I_Event) {
+		if err := r.Write(time.Now(), ev); err != nil {
+			log.Errorf("录制事件失败: %s", err)
+		}
+	})
+	cancelClose := gonebot.GlobalHooks.EngineWillTerminate(func(e *gonebot.Engine) {
+		if e == engine {
+			r.Close()
+		}
+	})
+	return func() {
+		cancelRecord()
+		cancelClose()
+	}
+}
+
+// 写入一个事件
+func (r *Recorder) Write(t time.Time, ev gonebot.I_Event) error {
+	data, err := gonebot.MarshalEvent(ev)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(Record{Time: t, Event: data})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return os.ErrClosed
+	}
+	if _, err = r.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	// 每个事件都落盘，以免程序异常退出时丢失
+	return r.w.Flush()
+}
+
+// 关闭录制文件
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return nil
+	}
+	r.w.Flush()
+	r.w = nil
+	return r.file.Close()
+}
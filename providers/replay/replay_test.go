@@ -0,0 +1,62 @@
+package replay
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/liwh011/gonebot"
+)
+
+func Test_RecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := &gonebot.PrivateMessageEvent{}
+	ev.PostType = gonebot.PostType_MessageEvent
+	ev.MessageType = "private"
+	ev.SubType = "friend"
+	ev.SelfId = 10000
+	ev.UserId = 114514
+	ev.Message = gonebot.MsgPrint("hello", gonebot.MsgFactory.Face(1))
+	ev.Sender = &gonebot.MessageEventSender{UserId: 114514}
+
+	start := time.Now()
+	rec.Write(start, ev)
+	rec.Write(start.Add(time.Hour), ev)
+	rec.Close()
+
+	p := NewReplayProvider(ReplayConfig{File: path, Speed: 3600 * 20})
+	p.Init(&gonebot.BaseConfig{})
+	ch := make(chan gonebot.I_Event, 2)
+	p.RecieveEvent(ch)
+	go p.Start()
+
+	select {
+	case <-p.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("回放超时")
+	}
+	if len(ch) != 2 {
+		t.Fatalf("应回放2个事件，实际%d个", len(ch))
+	}
+	got, ok := (<-ch).(*gonebot.PrivateMessageEvent)
+	if !ok || got.UserId != 114514 || !got.Message.Equal(ev.Message) {
+		t.Errorf("回放的事件与录制的不一致：%#v", got)
+	}
+	if got.GetEventName() != "message.private.friend" {
+		t.Errorf("事件名称为%s", got.GetEventName())
+	}
+
+	bot := &gonebot.Bot{}
+	bot.Init(p)
+	if _, err := bot.SendPrivateMsg(1, gonebot.MsgPrint("hi"), false); err != nil {
+		t.Error(err)
+	}
+	if reqs := p.Requests(); len(reqs) != 1 || reqs[0].Route != "send_private_msg" {
+		t.Errorf("应拦截API调用，实际为%v", reqs)
+	}
+}
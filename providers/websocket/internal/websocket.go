@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -12,11 +14,14 @@ import (
 
 type WebsocketClient struct {
 	conn        *websocket.Conn
-	url         string // websocket服务器地址
+	connLock    sync.Mutex // 保护conn，Reconnect与重新连接时会替换
+	writeLock   sync.Mutex // 同一时间只能有一个协程写入
+	url         string     // websocket服务器地址
 	accessToken string
 
-	reconnectTimeout int // 重连超时时间
-	closeSignal      chan bool
+	reconnectTimeout int           // 重连超时时间
+	closeSignal      chan struct{} // Stop时关闭
+	closeOnce        sync.Once
 
 	recieveChan chan<- []byte
 }
@@ -26,26 +31,33 @@ func NewWebsocketClient(url string, accessToken string, recieveChan chan<- []byt
 		url:              url,
 		accessToken:      accessToken,
 		reconnectTimeout: 3,
-		closeSignal:      make(chan bool),
+		closeSignal:      make(chan struct{}),
 		recieveChan:      recieveChan,
 	}
 }
 
 func (wsc *WebsocketClient) connect() error {
-	var err error
-
 	header := http.Header{
 		"Authorization": []string{fmt.Sprintf("Bearer %s", wsc.accessToken)},
 	}
-	wsc.conn, _, err = websocket.DefaultDialer.Dial(wsc.url, header)
+	conn, _, err := websocket.DefaultDialer.Dial(wsc.url, header)
 	if err != nil {
 		return err
 	}
+	wsc.connLock.Lock()
+	wsc.conn = conn
+	wsc.connLock.Unlock()
 	return nil
 }
 
-func (wsc *WebsocketClient) readMessage(msgChan chan<- []byte, errChan chan<- error) {
-	_, msgBytes, err := wsc.conn.ReadMessage()
+func (wsc *WebsocketClient) getConn() *websocket.Conn {
+	wsc.connLock.Lock()
+	defer wsc.connLock.Unlock()
+	return wsc.conn
+}
+
+func readMessage(conn *websocket.Conn, msgChan chan<- []byte, errChan chan<- error) {
+	_, msgBytes, err := conn.ReadMessage()
 	if err != nil {
 		errChan <- err
 	} else {
@@ -55,16 +67,22 @@ func (wsc *WebsocketClient) readMessage(msgChan chan<- []byte, errChan chan<- er
 
 // 读取消息，手动关闭时不会返回错误，其他情况下会返回错误
 func (wsc *WebsocketClient) readMsgLoop() error {
+	conn := wsc.getConn()
 	for {
-		msgChan := make(chan []byte)
-		errChan := make(chan error)
-		go wsc.readMessage(msgChan, errChan)
+		// 带缓冲，以免手动关闭后读取协程阻塞
+		msgChan := make(chan []byte, 1)
+		errChan := make(chan error, 1)
+		go readMessage(conn, msgChan, errChan)
 
 		select {
 		case msg := <-msgChan:
-			wsc.recieveChan <- msg
+			select {
+			case wsc.recieveChan <- msg:
+			case <-wsc.closeSignal:
+				return nil
+			}
 		case err := <-errChan:
-			wsc.closeSignal <- true
+			conn.Close()
 			return err
 
 		case <-wsc.closeSignal:
@@ -73,43 +91,82 @@ func (wsc *WebsocketClient) readMsgLoop() error {
 	}
 }
 
+var errNotConnected = errors.New("尚未连接到WebSocket服务器")
+
 func (wsc *WebsocketClient) Send(data []byte) error {
-	err := wsc.conn.WriteMessage(websocket.TextMessage, data)
-	return err
+	conn := wsc.getConn()
+	if conn == nil {
+		return errNotConnected
+	}
+	wsc.writeLock.Lock()
+	defer wsc.writeLock.Unlock()
+	return conn.WriteMessage(websocket.TextMessage, data)
 }
 
 // 开启服务并重连
 func (wsc *WebsocketClient) Start() {
 	go func() {
+		defer func() {
+			// 正常关闭
+			close(wsc.recieveChan)
+			if conn := wsc.getConn(); conn != nil {
+				conn.Close()
+			}
+			log.Info("与WebSocket服务器的连接已断开")
+		}()
+
 		// 启动连接到WebSocket服务器
-		for {
+		for !wsc.stopped() {
 			log.Infof("正在连接到Websocket服务器：%s", wsc.url)
 			err := wsc.connect()
 			if err != nil {
 				log.Errorf("连接到WebSocket服务器失败：%v", err)
-				time.Sleep(time.Duration(wsc.reconnectTimeout) * time.Second)
+				wsc.waitReconnect()
 				continue
 			}
 			log.Info("连接到Websocket服务器成功")
 
 			err = wsc.readMsgLoop()
-			if err != nil {
-				log.Errorf("读取消息失败：%v", err)
-				time.Sleep(time.Duration(wsc.reconnectTimeout) * time.Second)
-			} else {
-				// 正常关闭
-				close(wsc.recieveChan)
-				wsc.conn.Close()
-				log.Info("与WebSocket服务器的连接已断开")
+			if err == nil {
 				return
 			}
+			log.Errorf("读取消息失败：%v", err)
+			wsc.waitReconnect()
 		}
 	}()
 }
 
+// 是否已调用Stop
+func (wsc *WebsocketClient) stopped() bool {
+	select {
+	case <-wsc.closeSignal:
+		return true
+	default:
+		return false
+	}
+}
+
+// 等待一段时间后重连，调用Stop时立即返回
+func (wsc *WebsocketClient) waitReconnect() {
+	select {
+	case <-time.After(time.Duration(wsc.reconnectTimeout) * time.Second):
+	case <-wsc.closeSignal:
+	}
+}
+
+// 断开当前连接，随后会自动重新连接
+func (wsc *WebsocketClient) Reconnect() {
+	wsc.connLock.Lock()
+	defer wsc.connLock.Unlock()
+	if wsc.conn != nil {
+		log.Info("正在断开与WebSocket服务器的连接以重新连接")
+		wsc.conn.Close()
+	}
+}
+
 func (wsc *WebsocketClient) Stop() {
 	log.Info("正在断开与WebSocket服务器的连接")
-	wsc.closeSignal <- true
+	wsc.closeOnce.Do(func() { close(wsc.closeSignal) })
 	// for _, sub := range wsc.subscribers {
 	// 	close(sub)
 	// }
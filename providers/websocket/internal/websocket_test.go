@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_StopWhileReconnecting(t *testing.T) {
+	ch := make(chan []byte)
+	// 没有服务器监听的端口，连接会失败并等待重连
+	wsc := NewWebsocketClient("ws://127.0.0.1:1", "", ch)
+	wsc.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		wsc.Stop()
+		wsc.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("等待重连时Stop不应阻塞")
+	}
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("不应收到消息")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop后应关闭接收消息的通道")
+	}
+}
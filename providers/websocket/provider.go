@@ -79,6 +79,11 @@ func (p *WebsocketClientProvider) Stop() {
 	p.wsc.Stop()
 }
 
+// 心跳丢失时由框架调用，断开并重新连接
+func (p *WebsocketClientProvider) Reconnect() {
+	p.wsc.Reconnect()
+}
+
 func (p *WebsocketClientProvider) Request(route string, data interface{}) (interface{}, error) {
 	req := request{
 		Action: route,
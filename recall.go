@@ -0,0 +1,195 @@
+package gonebot
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 一条等待撤回的消息
+type pendingRecall struct {
+	MessageId int32     `json:"message_id"`
+	Deadline  time.Time `json:"deadline"` // 撤回时间
+	timer     *time.Timer
+}
+
+// 定时撤回消息的调度器，由Engine持有，不随Handler返回而消失
+type recallScheduler struct {
+	bot     *Bot
+	pending map[int32]*pendingRecall
+	mu      sync.Mutex
+}
+
+func newRecallScheduler(bot *Bot) *recallScheduler {
+	return &recallScheduler{
+		bot:     bot,
+		pending: make(map[int32]*pendingRecall),
+	}
+}
+
+// 在deadline撤回消息。同一条消息重复调度时，以最后一次为准
+func (r *recallScheduler) schedule(messageId int32, deadline time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.pending[messageId]; ok {
+		old.timer.Stop()
+	}
+	p := &pendingRecall{MessageId: messageId, Deadline: deadline}
+	p.timer = time.AfterFunc(time.Until(deadline), func() {
+		r.fire(p)
+	})
+	r.pending[messageId] = p
+}
+
+func (r *recallScheduler) fire(p *pendingRecall) {
+	r.mu.Lock()
+	if r.pending[p.MessageId] != p {
+		// 已被取消或重新调度
+		r.mu.Unlock()
+		return
+	}
+	delete(r.pending, p.MessageId)
+	r.mu.Unlock()
+
+	if err := r.bot.DeleteMsg(p.MessageId); err != nil {
+		log.Errorf("定时撤回消息%d失败: %s", p.MessageId, err)
+	}
+}
+
+// 取消撤回，返回该消息是否在等待撤回
+func (r *recallScheduler) cancel(messageId int32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pending[messageId]
+	if !ok {
+		return false
+	}
+	p.timer.Stop()
+	delete(r.pending, messageId)
+	return true
+}
+
+// 取出所有等待撤回的消息，并停止它们的定时器
+func (r *recallScheduler) takeAll() []*pendingRecall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret := make([]*pendingRecall, 0, len(r.pending))
+	for _, p := range r.pending {
+		p.timer.Stop()
+		ret = append(ret, p)
+	}
+	r.pending = make(map[int32]*pendingRecall)
+	return ret
+}
+
+// 立即撤回所有等待撤回的消息
+func (r *recallScheduler) flush() {
+	for _, p := range r.takeAll() {
+		if err := r.bot.DeleteMsg(p.MessageId); err != nil {
+			log.Errorf("撤回消息%d失败: %s", p.MessageId, err)
+		}
+	}
+}
+
+// 将所有等待撤回的消息写入文件，下次启动时由restore继续
+func (r *recallScheduler) persist(path string) error {
+	pending := r.takeAll()
+	if len(pending) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// 从文件恢复等待撤回的消息，已过期的将立即撤回。恢复后删除该文件
+func (r *recallScheduler) restore(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var pending []*pendingRecall
+	if err = json.Unmarshal(data, &pending); err != nil {
+		return err
+	}
+	for _, p := range pending {
+		r.schedule(p.MessageId, p.Deadline)
+	}
+	log.Infof("已恢复%d条等待撤回的消息", len(pending))
+	return os.Remove(path)
+}
+
+// Engine结束前，处理所有还未撤回的消息。配置了recall_persist_file时写入文件，否则立即撤回
+func (r *recallScheduler) terminate(cfg Config) {
+	path := cfg.GetBaseConfig().RecallPersistFile
+	if path == "" {
+		r.flush()
+		return
+	}
+	if err := r.persist(path); err != nil {
+		log.Errorf("保存等待撤回的消息失败: %s", err)
+	}
+}
+
+// 在d时长后撤回消息
+func (bot *Bot) RecallAfter(messageId int32, d time.Duration) {
+	bot.recalls.schedule(messageId, time.Now().Add(d))
+}
+
+// 取消消息的定时撤回，返回该消息是否在等待撤回
+func (bot *Bot) CancelRecall(messageId int32) bool {
+	return bot.recalls.cancel(messageId)
+}
+
+// 发送私聊消息，并在ttl时长后自动撤回
+func (bot *Bot) SendPrivateMsgWithTTL(userId int64, message Message, autoEscape bool, ttl time.Duration) (int32, error) {
+	messageId, err := bot.SendPrivateMsg(userId, message, autoEscape)
+	if err != nil {
+		return messageId, err
+	}
+	bot.RecallAfter(messageId, ttl)
+	return messageId, nil
+}
+
+// 发送群消息，并在ttl时长后自动撤回
+func (bot *Bot) SendGroupMsgWithTTL(groupId int64, message Message, autoEscape bool, ttl time.Duration) (int32, error) {
+	messageId, err := bot.SendGroupMsg(groupId, message, autoEscape)
+	if err != nil {
+		return messageId, err
+	}
+	bot.RecallAfter(messageId, ttl)
+	return messageId, nil
+}
+
+// 回复一条消息，并在d时长后自动撤回。返回消息ID，可用Bot.CancelRecall取消撤回
+func (ctx *Context) ReplyEphemeral(d time.Duration, args ...interface{}) (messageId int32, err error) {
+	msg := MsgPrint(args...)
+	switch ev := ctx.Event.(type) {
+	case *GroupMessageEvent:
+		if ctx.atSenderWhenReply {
+			msg = append(Message{MsgFactory.AtSomeone(ev.UserId), MsgFactory.Text(" ")}, msg...)
+		}
+		messageId, err = ctx.Bot.SendGroupMsgWithTTL(ev.GroupId, msg, false, d)
+	case *PrivateMessageEvent:
+		messageId, err = ctx.Bot.SendPrivateMsgWithTTL(ev.UserId, msg, false, d)
+	default:
+		log.Warnf("该事件不是私聊或群聊消息事件，无法回复消息。(类型%s)", ctx.Event.GetEventName())
+		return -1, nil
+	}
+	if err != nil {
+		log.Errorf("回复消息失败: %s", err.Error())
+	}
+	return
+}
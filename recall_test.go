@@ -0,0 +1,111 @@
+package gonebot
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录API调用的Provider，供测试使用
+type recordingProvider struct {
+	mu    sync.Mutex
+	calls []recordedCall
+}
+
+type recordedCall struct {
+	Route string
+	Data  interface{}
+}
+
+func (p *recordingProvider) Init(cfg Config)                {}
+func (p *recordingProvider) Start()                         {}
+func (p *recordingProvider) Stop()                          {}
+func (p *recordingProvider) RecieveEvent(ch chan<- I_Event) {}
+func (p *recordingProvider) OnEventHandled(ev I_Event)      {}
+
+func (p *recordingProvider) Request(route string, data interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, recordedCall{route, data})
+	return map[string]interface{}{"message_id": len(p.calls)}, nil
+}
+
+func (p *recordingProvider) routes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := make([]string, 0, len(p.calls))
+	for _, c := range p.calls {
+		ret = append(ret, c.Route)
+	}
+	return ret
+}
+
+func newTestBot() (*Bot, *recordingProvider) {
+	provider := &recordingProvider{}
+	bot := &Bot{}
+	bot.Init(provider)
+	bot.config = &configHolder{}
+	bot.config.store(&BaseConfig{})
+	return bot, provider
+}
+
+func Test_RecallAfter(t *testing.T) {
+	bot, provider := newTestBot()
+
+	bot.RecallAfter(1, 10*time.Millisecond)
+	bot.RecallAfter(2, 10*time.Millisecond)
+	if !bot.CancelRecall(2) {
+		t.Error("消息2应处于等待撤回状态")
+	}
+	if bot.CancelRecall(3) {
+		t.Error("消息3不应处于等待撤回状态")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	routes := provider.routes()
+	if len(routes) != 1 || routes[0] != "delete_msg" {
+		t.Errorf("应只撤回一条消息，实际调用：%v", routes)
+	}
+}
+
+func Test_RecallPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recalls.json")
+
+	bot, provider := newTestBot()
+	bot.RecallAfter(1, time.Hour)
+	bot.recalls.terminate(&BaseConfig{RecallPersistFile: path})
+	if len(provider.routes()) != 0 {
+		t.Error("配置了保存文件时不应立即撤回")
+	}
+
+	bot2, _ := newTestBot()
+	if err := bot2.recalls.restore(path); err != nil {
+		t.Fatal(err)
+	}
+	if !bot2.CancelRecall(1) {
+		t.Error("恢复后消息1应处于等待撤回状态")
+	}
+
+	bot3, provider3 := newTestBot()
+	bot3.RecallAfter(5, time.Hour)
+	bot3.recalls.terminate(&BaseConfig{})
+	if routes := provider3.routes(); len(routes) != 1 || routes[0] != "delete_msg" {
+		t.Errorf("未配置保存文件时应立即撤回，实际调用：%v", routes)
+	}
+}
+
+func Test_EngineTerminate(t *testing.T) {
+	hooks := len(GlobalHooks.hookMap[engineLifecycleHook_EngineWillTerminate])
+	provider := &recordingProvider{}
+	engine := NewEngineWithProvider(&BaseConfig{}, provider)
+	if n := len(GlobalHooks.hookMap[engineLifecycleHook_EngineWillTerminate]); n != hooks {
+		t.Errorf("创建Engine不应注册全局钩子，多了%d个", n-hooks)
+	}
+
+	engine.bot.RecallAfter(1, time.Hour)
+	engine.terminate()
+	if routes := provider.routes(); len(routes) != 1 || routes[0] != "delete_msg" {
+		t.Errorf("退出时应撤回等待中的消息，实际调用：%v", routes)
+	}
+}
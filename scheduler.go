@@ -0,0 +1,363 @@
+package gonebot
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 时钟，定时任务通过它获取时间和等待，测试时可以替换
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+}
+
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) ClockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// 错过执行时间时的处理方式。程序暂停、重启，或上一次执行时间过长等都可能错过
+type MissedRunPolicy int
+
+const (
+	MissedRun_RunOnce MissedRunPolicy = iota // 补执行一次，默认
+	MissedRun_RunAll                         // 每错过一次补执行一次，最多补执行maxMissedRuns次
+	MissedRun_Skip                           // 不补执行，等待下一次
+)
+
+// 最多补执行的次数
+const maxMissedRuns = 100
+
+// 执行时间晚于计划时间超过该值时视为错过
+const missedRunTolerance = time.Minute
+
+// 定时任务的选项
+type ScheduleOptions struct {
+	Location     *time.Location  // 计算执行时间使用的时区，默认为本地时区
+	MissedRun    MissedRunPolicy // 错过执行时间时的处理方式
+	AllowOverlap bool            // 上一次还未执行完时是否仍然执行，默认跳过本次
+	// 任务名，填写后上次执行的时间会保存在插件的存储中，重启后可以补上停机期间错过的执行
+	Name string
+}
+
+// 定时任务
+type ScheduledJob struct {
+	schedule jobSchedule
+	opt      ScheduleOptions
+	f        func(bot *Bot)
+	hub      *PluginHub
+
+	last     time.Time // 上一次处理过的计划执行时间
+	running  atomic.Bool
+	fromInit bool // 是否在插件的Init中添加
+	paused   atomic.Bool
+	wake     chan struct{} // 暂停、恢复时通知任务的协程
+	done     chan struct{}
+	once     sync.Once
+}
+
+// 取消任务，正在执行的不会被中断
+func (job *ScheduledJob) Cancel() {
+	job.once.Do(func() {
+		close(job.done)
+		job.hub.engine.scheduler.remove(job)
+		job.hub.removeJob(job)
+	})
+}
+
+// 插件被禁用时暂停，暂停期间错过的执行在恢复后按MissedRun处理
+func (job *ScheduledJob) pause() {
+	job.paused.Store(true)
+	job.notify()
+}
+
+func (job *ScheduledJob) resume() {
+	job.paused.Store(false)
+	job.notify()
+}
+
+func (job *ScheduledJob) notify() {
+	select {
+	case job.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 插件的所有定时任务，调用时需持有p.mu
+func (p *PluginHub) listJobs() []*ScheduledJob {
+	jobs := make([]*ScheduledJob, 0, len(p.jobs))
+	for job := range p.jobs {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func (p *PluginHub) removeJob(job *ScheduledJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.jobs, job)
+}
+
+// Engine上所有的定时任务
+type scheduler struct {
+	clock Clock
+	jobs  map[*ScheduledJob]struct{}
+	mu    sync.Mutex
+}
+
+func (s *scheduler) getClock() Clock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clock == nil {
+		return realClock{}
+	}
+	return s.clock
+}
+
+func (s *scheduler) add(job *ScheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs == nil {
+		s.jobs = make(map[*ScheduledJob]struct{})
+	}
+	s.jobs[job] = struct{}{}
+}
+
+func (s *scheduler) remove(job *ScheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, job)
+}
+
+// 取消所有任务
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	jobs := make([]*ScheduledJob, 0, len(s.jobs))
+	for job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+	for _, job := range jobs {
+		job.Cancel()
+	}
+}
+
+// 替换定时任务使用的时钟，只影响之后添加的任务
+func (engine *Engine) SetClock(clock Clock) {
+	engine.scheduler.mu.Lock()
+	defer engine.scheduler.mu.Unlock()
+	engine.scheduler.clock = clock
+}
+
+// 按cron表达式定时执行f，格式为“分 时 日 月 星期”，如“0 8 * * *”为每天8点，
+// 也可以使用@daily、@hourly、“@every 30m”等。opt为nil时使用默认选项。
+// 插件被禁用时任务暂停而不是取消：Init中添加的任务会在重新启用时随Init重新添加，其他时候添加的任务
+// 无法由框架重新创建，因此在重新启用后恢复执行。需要在禁用时彻底停止的任务，请在OnUnload中调用其Cancel。
+// Engine退出时所有任务会被取消
+func (p *PluginHub) Schedule(cronExpr string, f func(bot *Bot), opt *ScheduleOptions) (*ScheduledJob, error) {
+	var o ScheduleOptions
+	if opt != nil {
+		o = *opt
+	}
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+	schedule, err := parseCron(cronExpr, o.Location)
+	if err != nil {
+		return nil, err
+	}
+	return p.addJob(schedule, f, o, time.Time{}), nil
+}
+
+// 在at时执行一次f。at已经过去时按opt.MissedRun处理，默认立即执行
+func (p *PluginHub) ScheduleOnce(at time.Time, f func(bot *Bot), opt *ScheduleOptions) *ScheduledJob {
+	var o ScheduleOptions
+	if opt != nil {
+		o = *opt
+	}
+	// 从at之前开始计算，at已经过去时视为错过
+	return p.addJob(&onceSchedule{at: at}, f, o, at.Add(-time.Nanosecond))
+}
+
+func (p *PluginHub) addJob(schedule jobSchedule, f func(bot *Bot), opt ScheduleOptions, last time.Time) *ScheduledJob {
+	job := &ScheduledJob{
+		schedule: schedule,
+		opt:      opt,
+		f:        f,
+		hub:      p,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	clock := p.engine.scheduler.getClock()
+	if last.IsZero() {
+		last = clock.Now()
+		if t, ok := job.loadLastRun(); ok && t.Before(last) {
+			last = t
+		}
+	}
+	job.last = last
+
+	// 插件被禁用时添加的任务在启用后才开始执行
+	p.mu.Lock()
+	if p.jobs == nil {
+		p.jobs = make(map[*ScheduledJob]struct{})
+	}
+	p.jobs[job] = struct{}{}
+	job.fromInit = p.initializing
+	job.paused.Store(!p.enabled)
+	p.mu.Unlock()
+
+	p.engine.scheduler.add(job)
+	go job.loop(clock)
+	return job
+}
+
+func (job *ScheduledJob) storageKey() string {
+	return "_schedule/" + job.opt.Name
+}
+
+func (job *ScheduledJob) loadLastRun() (time.Time, bool) {
+	if job.opt.Name == "" {
+		return time.Time{}, false
+	}
+	t, ok, err := GetJSON[time.Time](job.hub.Storage(), job.storageKey())
+	if err != nil {
+		log.Errorf("读取定时任务%s上次执行的时间失败：%s", job.opt.Name, err)
+	}
+	return t, ok
+}
+
+func (job *ScheduledJob) saveLastRun() {
+	if job.opt.Name == "" {
+		return
+	}
+	if err := SetJSON(job.hub.Storage(), job.storageKey(), job.last, 0); err != nil {
+		log.Errorf("保存定时任务%s执行的时间失败：%s", job.opt.Name, err)
+	}
+}
+
+func (job *ScheduledJob) loop(clock Clock) {
+	defer job.Cancel()
+	for {
+		for job.paused.Load() {
+			select {
+			case <-job.wake:
+			case <-job.done:
+				return
+			}
+		}
+
+		due := job.schedule.next(job.last)
+		if due.IsZero() {
+			return
+		}
+
+		if now := clock.Now(); due.After(now) {
+			timer := clock.NewTimer(due.Sub(now))
+			select {
+			case <-timer.C():
+			case <-job.wake:
+				// 暂停后重新计时
+				timer.Stop()
+				continue
+			case <-job.done:
+				timer.Stop()
+				return
+			}
+		}
+
+		select {
+		case <-job.done:
+			return
+		default:
+		}
+		if job.paused.Load() {
+			continue
+		}
+		job.runDue(due, clock.Now())
+	}
+}
+
+// 处理从due到now之间的所有计划执行时间
+func (job *ScheduledJob) runDue(due, now time.Time) {
+	times := 1
+	switch job.opt.MissedRun {
+	case MissedRun_RunAll:
+		job.last = due
+		for next := job.schedule.next(due); !next.IsZero() && !next.After(now); next = job.schedule.next(next) {
+			if times == maxMissedRuns {
+				job.last = now
+				break
+			}
+			times++
+			job.last = next
+		}
+	case MissedRun_Skip:
+		job.last = now
+		if next := job.schedule.next(due); now.Sub(due) > missedRunTolerance || !next.IsZero() && !next.After(now) {
+			log.Debugf("定时任务错过了执行时间%s，跳过", due.Format(time.DateTime))
+			times = 0
+		}
+	default:
+		job.last = now
+	}
+	job.saveLastRun()
+	if times > 0 {
+		job.run(times)
+	}
+}
+
+// 在新的协程中执行times次，不阻塞下一次的计时
+func (job *ScheduledJob) run(times int) {
+	if !job.running.CompareAndSwap(false, true) {
+		if !job.opt.AllowOverlap {
+			log.Warnf("插件%s的定时任务上一次还未执行完，跳过本次", job.hub.GetPluginId())
+			return
+		}
+	}
+	go func() {
+		defer job.running.Store(false)
+		for i := 0; i < times; i++ {
+			job.call()
+		}
+	}()
+}
+
+// 执行一次任务。插件熔断时跳过，panic与处理函数的一样计入统计与熔断
+func (job *ScheduledJob) call() {
+	hub := job.hub
+	if hub.breaker.isTripped(hub.engine.now()) {
+		log.Debugf("插件%s处于熔断中，跳过定时任务", hub.GetPluginId())
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			hub.stats.panics.Add(1)
+			log.Errorf("插件%s的定时任务出错：%v\n%s", hub.GetPluginId(), err, debug.Stack())
+			hub.recordFailure("panic")
+		}
+	}()
+	job.f(hub.GetBot())
+}
@@ -0,0 +1,236 @@
+package gonebot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_parseCron(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 30, 0, time.UTC) // 周日
+	tests := []struct {
+		expr string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"*/15 * * * *", time.UTC, time.Date(2023, 1, 1, 0, 15, 0, 0, time.UTC)},
+		{"0 8 * * *", time.UTC, time.Date(2023, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.UTC, time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.UTC, time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.UTC, time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)}, // 日和星期满足其一即可
+		{"0 0 * JAN-MAR 7", time.UTC, time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.UTC, time.Date(2023, 1, 1, 0, 5, 0, 0, time.UTC)},
+		{"30 2 29 2 *", time.UTC, time.Date(2024, 2, 29, 2, 30, 0, 0, time.UTC)},
+		{"@hourly", time.UTC, time.Date(2023, 1, 1, 1, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.UTC, time.Date(2023, 1, 1, 0, 2, 0, 0, time.UTC)},
+		// UTC+8的8点为UTC的0点，已经过去
+		{"0 8 * * *", time.FixedZone("CST", 8*3600), time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr, tt.loc)
+		if err != nil {
+			t.Errorf("%q: %s", tt.expr, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: 下一次应为%s，实际为%s", tt.expr, tt.want, got)
+		}
+	}
+
+	for _, expr := range []string{"61 * * * *", "* * *", "0 0 * * 8", "0 0 0 * *", "*/0 * * * *", "@every x", "@often"} {
+		if _, err := parseCron(expr, time.UTC); !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("%q应返回ErrInvalidCron，实际为%v", expr, err)
+		}
+	}
+}
+
+// 手动拨动的时钟
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+	mu     sync.Mutex
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return true
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+	} else {
+		c.timers = append(c.timers, t)
+	}
+	return t
+}
+
+// 等待任务开始计时
+func (c *fakeClock) waitTimer(t *testing.T) {
+	for i := 0; ; i++ {
+		c.mu.Lock()
+		n := len(c.timers)
+		c.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("任务没有开始计时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 等待任务开始计时后再拨动时钟
+func (c *fakeClock) advance(t *testing.T, d time.Duration) {
+	c.waitTimer(t)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := []*fakeTimer{}
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// 在timeout内收到的次数
+func countRuns(ch chan struct{}, timeout time.Duration) int {
+	n := 0
+	for {
+		select {
+		case <-ch:
+			n++
+		case <-time.After(timeout):
+			return n
+		}
+	}
+}
+
+func Test_Schedule(t *testing.T) {
+	newHub := func() (*PluginHub, *fakeClock) {
+		clock := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+		engine := &Engine{}
+		engine.SetClock(clock)
+		return &PluginHub{engine: engine, plugin: &unloadablePlugin{name: "schedule"}, enabled: true}, clock
+	}
+
+	tests := []struct {
+		name    string
+		policy  MissedRunPolicy
+		advance time.Duration
+		want    int
+	}{
+		{"按时执行", MissedRun_RunOnce, time.Minute, 1},
+		{"错过时补执行一次", MissedRun_RunOnce, 3 * time.Minute, 1},
+		{"错过时全部补执行", MissedRun_RunAll, 3 * time.Minute, 3},
+		{"错过时跳过", MissedRun_Skip, 3 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		hub, clock := newHub()
+		ran := make(chan struct{}, 10)
+		job, err := hub.Schedule("* * * * *", func(bot *Bot) { ran <- struct{}{} }, &ScheduleOptions{MissedRun: tt.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.advance(t, tt.advance)
+		if n := countRuns(ran, 50*time.Millisecond); n != tt.want {
+			t.Errorf("%s: 应执行%d次，实际为%d次", tt.name, tt.want, n)
+		}
+		job.Cancel()
+	}
+
+	// 上一次还未执行完时跳过
+	hub, clock := newHub()
+	ran := make(chan struct{}, 10)
+	release := make(chan struct{})
+	hub.Schedule("@every 1m", func(bot *Bot) {
+		ran <- struct{}{}
+		<-release
+	}, nil)
+	clock.advance(t, time.Minute)
+	clock.advance(t, time.Minute)
+	clock.waitTimer(t) // 第二次已处理完
+	close(release)
+	if n := countRuns(ran, 50*time.Millisecond); n != 1 {
+		t.Errorf("上一次未执行完时应跳过，实际执行%d次", n)
+	}
+
+	// 已经过去的一次性任务立即执行；Engine退出时取消所有任务
+	hub.ScheduleOnce(clock.Now().Add(-time.Hour), func(bot *Bot) { ran <- struct{}{} }, nil)
+	if n := countRuns(ran, 50*time.Millisecond); n != 1 {
+		t.Errorf("已经过去的一次性任务应立即执行，实际执行%d次", n)
+	}
+	hub.engine.scheduler.stop()
+	clock.advance(t, time.Minute)
+	if n := countRuns(ran, 50*time.Millisecond); n != 0 {
+		t.Errorf("取消后不应再执行，实际执行%d次", n)
+	}
+	if len(hub.engine.scheduler.jobs) != 0 {
+		t.Errorf("取消后应移除所有任务，还剩%d个", len(hub.engine.scheduler.jobs))
+	}
+}
+
+func Test_SchedulePausedWithPlugin(t *testing.T) {
+	RegisterPlugin(&idlePlugin{name: "pause"}, nil)
+	defer delete(defaultPluginManager.plugins, "pause@t")
+
+	engine := NewEngineWithProvider(&BaseConfig{}, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	defer engine.scheduler.stop()
+	clock := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	engine.SetClock(clock)
+	hub := engine.plugins.get("pause@t")
+
+	// 不在Init中添加的任务，禁用时暂停，启用后恢复
+	ran := make(chan struct{}, 10)
+	job, err := hub.Schedule("@every 1m", func(bot *Bot) { ran <- struct{}{} }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.waitTimer(t)
+	if err := engine.DisablePlugin("pause@t"); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(t, 3*time.Minute)
+	if n := countRuns(ran, 50*time.Millisecond); n != 0 {
+		t.Errorf("插件被禁用时任务不应执行，实际执行%d次", n)
+	}
+	if err := engine.EnablePlugin("pause@t"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRuns(ran, 50*time.Millisecond); n != 1 {
+		t.Errorf("重新启用后应补执行1次，实际执行%d次", n)
+	}
+
+	// 取消后从插件中移除
+	job.Cancel()
+	hub.mu.Lock()
+	n := len(hub.jobs)
+	hub.mu.Unlock()
+	if n != 0 {
+		t.Errorf("取消后应从插件中移除，还剩%d个", n)
+	}
+}
@@ -0,0 +1,353 @@
+package gonebot
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 存储的配置
+type StorageConfig struct {
+	Type string `yaml:"type"` // memory或bolt，默认memory，重启后数据丢失
+	Path string `yaml:"path"` // bolt存储的文件路径，默认data.db
+}
+
+// 键值存储。插件通过PluginHub.Storage获取，只能访问自己的数据
+type Storage interface {
+	// 读取键的值，键不存在或已过期时ok为false
+	Get(key string) (value []byte, ok bool, err error)
+	// 写入键的值，ttl大于0时在ttl后过期
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	// 以prefix开头的所有未过期的键，按字典序排列
+	List(prefix string) ([]string, error)
+	Close() error
+}
+
+// 读取键的值并以JSON解码，键不存在时ok为false
+func GetJSON[T any](s Storage, key string) (value T, ok bool, err error) {
+	data, ok, err := s.Get(key)
+	if err != nil || !ok {
+		return value, false, err
+	}
+	if err = json.Unmarshal(data, &value); err != nil {
+		return value, false, err
+	}
+	return value, true, nil
+}
+
+// 将值以JSON编码后写入，ttl大于0时在ttl后过期
+func SetJSON(s Storage, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.Set(key, data, ttl)
+}
+
+// 过期时间，零值表示不过期
+func storageExpireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func storageExpired(expireAt time.Time) bool {
+	return !expireAt.IsZero() && !time.Now().Before(expireAt)
+}
+
+/*
+ * 内存存储
+ */
+
+type memoryEntry struct {
+	value    []byte
+	expireAt time.Time
+}
+
+type memoryStorage struct {
+	entries map[string]memoryEntry
+	mu      sync.Mutex
+}
+
+// 保存在内存中的存储，重启后数据丢失，可用于测试
+func NewMemoryStorage() Storage {
+	return &memoryStorage{entries: make(map[string]memoryEntry)}
+}
+
+func (s *memoryStorage) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if storageExpired(e.expireAt) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	return append([]byte(nil), e.value...), true, nil
+}
+
+func (s *memoryStorage) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryEntry{value: append([]byte(nil), value...), expireAt: storageExpireAt(ttl)}
+	return nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *memoryStorage) List(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []string{}
+	for key, e := range s.entries {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if storageExpired(e.expireAt) {
+			delete(s.entries, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
+
+/*
+ * bolt存储
+ */
+
+var boltBucket = []byte("gonebot")
+
+type boltStorage struct {
+	db *bolt.DB
+}
+
+// 保存在文件中的存储，基于bbolt。同一文件同时只能被一个程序打开
+func NewBoltStorage(path string) (Storage, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStorage{db: db}, nil
+}
+
+// 值的前8字节为过期时间的UnixNano，0表示不过期
+func encodeBoltValue(value []byte, ttl time.Duration) []byte {
+	data := make([]byte, 8+len(value))
+	if expireAt := storageExpireAt(ttl); !expireAt.IsZero() {
+		binary.BigEndian.PutUint64(data, uint64(expireAt.UnixNano()))
+	}
+	copy(data[8:], value)
+	return data
+}
+
+func decodeBoltValue(data []byte) (value []byte, expireAt time.Time) {
+	if len(data) < 8 {
+		return data, time.Time{}
+	}
+	if n := binary.BigEndian.Uint64(data); n != 0 {
+		expireAt = time.Unix(0, int64(n))
+	}
+	return data[8:], expireAt
+}
+
+func (s *boltStorage) Get(key string) (value []byte, ok bool, err error) {
+	expired := false
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		v, expireAt := decodeBoltValue(data)
+		if storageExpired(expireAt) {
+			expired = true
+			return nil
+		}
+		// 返回的切片只在事务中有效，需复制
+		value, ok = append([]byte(nil), v...), true
+		return nil
+	})
+	if err == nil && expired {
+		// 读写之间可能已被重新设置，删除前再检查一次
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(boltBucket)
+			if _, expireAt := decodeBoltValue(b.Get([]byte(key))); storageExpired(expireAt) {
+				return b.Delete([]byte(key))
+			}
+			return nil
+		})
+	}
+	return
+}
+
+func (s *boltStorage) Set(key string, value []byte, ttl time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), encodeBoltValue(value, ttl))
+	})
+}
+
+func (s *boltStorage) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (s *boltStorage) List(prefix string) ([]string, error) {
+	keys := []string{}
+	expired := [][]byte{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		p := []byte(prefix)
+		for k, v := c.Seek(p); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			if _, expireAt := decodeBoltValue(v); storageExpired(expireAt) {
+				expired = append(expired, append([]byte(nil), k...))
+				continue
+			}
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	if err != nil || len(expired) == 0 {
+		return keys, err
+	}
+	// 顺便删除已过期的键
+	return keys, s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		for _, k := range expired {
+			if _, expireAt := decodeBoltValue(b.Get(k)); storageExpired(expireAt) {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStorage) Close() error {
+	return s.db.Close()
+}
+
+/*
+ * 插件的命名空间
+ */
+
+// 为所有键加上前缀，List返回的键不含前缀。关闭时不关闭底层的存储
+type namespacedStorage struct {
+	storage Storage
+	prefix  string
+}
+
+func (s *namespacedStorage) Get(key string) ([]byte, bool, error) {
+	return s.storage.Get(s.prefix + key)
+}
+
+func (s *namespacedStorage) Set(key string, value []byte, ttl time.Duration) error {
+	return s.storage.Set(s.prefix+key, value, ttl)
+}
+
+func (s *namespacedStorage) Delete(key string) error {
+	return s.storage.Delete(s.prefix + key)
+}
+
+func (s *namespacedStorage) List(prefix string) ([]string, error) {
+	keys, err := s.storage.List(s.prefix + prefix)
+	for i := range keys {
+		keys[i] = keys[i][len(s.prefix):]
+	}
+	return keys, err
+}
+
+func (s *namespacedStorage) Close() error {
+	return nil
+}
+
+// 按配置打开存储
+func openStorage(cfg StorageConfig) (Storage, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemoryStorage(), nil
+	case "bolt":
+		path := cfg.Path
+		if path == "" {
+			path = "data.db"
+		}
+		return NewBoltStorage(path)
+	}
+	return nil, ErrUnknownStorageType
+}
+
+// 替换Engine使用的存储，原有的存储会被关闭，其中的数据不会被迁移
+func (engine *Engine) SetStorage(storage Storage) error {
+	engine.storageLock.Lock()
+	old := engine.storage
+	engine.storage = storage
+	engine.storageLock.Unlock()
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+func (engine *Engine) getStorage() Storage {
+	engine.storageLock.RLock()
+	defer engine.storageLock.RUnlock()
+	return engine.storage
+}
+
+// 插件专用的存储，键保存在“插件名@作者/”之下，与其他插件互不影响
+func (p *PluginHub) Storage() Storage {
+	return &namespacedStorage{storage: &engineStorage{p.engine}, prefix: p.GetPluginId() + "/"}
+}
+
+// 总是使用Engine当前的存储，SetStorage后插件拿到的存储仍然有效
+type engineStorage struct {
+	engine *Engine
+}
+
+func (s *engineStorage) Get(key string) ([]byte, bool, error) {
+	return s.engine.getStorage().Get(key)
+}
+
+func (s *engineStorage) Set(key string, value []byte, ttl time.Duration) error {
+	return s.engine.getStorage().Set(key, value, ttl)
+}
+
+func (s *engineStorage) Delete(key string) error {
+	return s.engine.getStorage().Delete(key)
+}
+
+func (s *engineStorage) List(prefix string) ([]string, error) {
+	return s.engine.getStorage().List(prefix)
+}
+
+func (s *engineStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package gonebot
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Storage(t *testing.T) {
+	bolt, err := NewBoltStorage(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	for name, s := range map[string]Storage{"memory": NewMemoryStorage(), "bolt": bolt} {
+		s.Set("score/1", []byte("10"), 0)
+		s.Set("score/2", []byte("20"), 0)
+		s.Set("cooldown/1", []byte("1"), 20*time.Millisecond)
+		s.Set("other", []byte("x"), 0)
+
+		if v, ok, err := s.Get("score/1"); err != nil || !ok || string(v) != "10" {
+			t.Errorf("%s: Get应返回10，实际为%q %v %v", name, v, ok, err)
+		}
+		if keys, _ := s.List("score/"); fmt.Sprint(keys) != "[score/1 score/2]" {
+			t.Errorf("%s: List结果有误：%v", name, keys)
+		}
+
+		s.Delete("score/1")
+		if _, ok, _ := s.Get("score/1"); ok {
+			t.Errorf("%s: 删除后不应存在", name)
+		}
+
+		if _, ok, _ := s.Get("cooldown/1"); !ok {
+			t.Errorf("%s: 未过期的键应存在", name)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if _, ok, _ := s.Get("cooldown/1"); ok {
+			t.Errorf("%s: 过期的键不应存在", name)
+		}
+		if keys, _ := s.List(""); fmt.Sprint(keys) != "[other score/2]" {
+			t.Errorf("%s: List不应包含过期的键：%v", name, keys)
+		}
+
+		type sub struct{ Groups []int64 }
+		SetJSON(s, "sub", sub{Groups: []int64{1, 2}}, 0)
+		if v, ok, err := GetJSON[sub](s, "sub"); err != nil || !ok || len(v.Groups) != 2 {
+			t.Errorf("%s: GetJSON结果有误：%v %v %v", name, v, ok, err)
+		}
+		if _, ok, err := GetJSON[sub](s, "none"); err != nil || ok {
+			t.Errorf("%s: 不存在的键ok应为false", name)
+		}
+	}
+}
+
+func Test_PluginStorage(t *testing.T) {
+	engine := &Engine{storage: NewMemoryStorage()}
+	a := (&PluginHub{engine: engine, plugin: &unloadablePlugin{name: "a"}}).Storage()
+	b := (&PluginHub{engine: engine, plugin: &unloadablePlugin{name: "b"}}).Storage()
+
+	a.Set("k", []byte("a"), 0)
+	b.Set("k", []byte("b"), 0)
+	if v, _, _ := a.Get("k"); string(v) != "a" {
+		t.Errorf("插件的数据应互相隔离，实际为%q", v)
+	}
+	if keys, _ := b.List(""); fmt.Sprint(keys) != "[k]" {
+		t.Errorf("List应只返回本插件的键，且不含前缀：%v", keys)
+	}
+	if keys, _ := engine.getStorage().List(""); fmt.Sprint(keys) != "[a@t/k b@t/k]" {
+		t.Errorf("底层的键应以插件ID为前缀：%v", keys)
+	}
+
+	// 替换存储后，插件拿到的存储使用新的存储
+	engine.SetStorage(NewMemoryStorage())
+	if _, ok, _ := a.Get("k"); ok {
+		t.Error("替换存储后应读取新的存储")
+	}
+}
@@ -0,0 +1,194 @@
+package gonebot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// 最多记录的消息ID数
+const messageIdCacheSize = 1024
+
+// 最近的若干个消息ID，用于判断消息是否回复了bot
+type messageIdCache struct {
+	ids  map[int64]struct{}
+	ring []int64
+	next int
+	mu   sync.Mutex
+}
+
+func newMessageIdCache() *messageIdCache {
+	return &messageIdCache{
+		ids:  make(map[int64]struct{}),
+		ring: make([]int64, 0, messageIdCacheSize),
+	}
+}
+
+func (c *messageIdCache) add(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.ids[id]; ok {
+		return
+	}
+	if len(c.ring) < messageIdCacheSize {
+		c.ring = append(c.ring, id)
+	} else {
+		delete(c.ids, c.ring[c.next])
+		c.ring[c.next] = id
+		c.next = (c.next + 1) % messageIdCacheSize
+	}
+	c.ids[id] = struct{}{}
+}
+
+func (c *messageIdCache) contains(id int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.ids[id]
+	return ok
+}
+
+// 记录发送消息类API返回的消息ID
+func (bot *Bot) recordSentMessage(action string, rsp *gjson.Result) {
+	if bot.sentMessages == nil || rsp == nil || !strings.HasPrefix(action, "send_") {
+		return
+	}
+	if id := rsp.Get("message_id"); id.Exists() {
+		bot.sentMessages.add(id.Int())
+	}
+}
+
+// 记录协议端上报的bot自身发出的消息。快速操作发出的消息不返回消息ID，只能从这里得知
+func (bot *Bot) recordSentEvent(ev I_Event) {
+	if bot.sentMessages == nil {
+		return
+	}
+	switch ev := ev.(type) {
+	case *PrivateMessageSentEvent:
+		bot.sentMessages.add(int64(ev.MessageId))
+	case *GroupMessageSentEvent:
+		bot.sentMessages.add(int64(ev.MessageId))
+	}
+}
+
+// 查询被回复的消息时最多等待多久，超时视为不是bot发出的，查询结果仍会记录下来
+const replyLookupTimeout = 2 * time.Second
+
+// 判断消息是否由bot发出。先查找最近发出的消息与查询过的消息，找不到时调用get_msg查询
+func (bot *Bot) isMessageFromMe(messageId int32) bool {
+	id := int64(messageId)
+	if bot.sentMessages == nil {
+		return false
+	}
+	if bot.sentMessages.contains(id) {
+		return true
+	}
+	if bot.otherMessages.contains(id) {
+		return false
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		data, err := bot.CallApi("get_msg", ApiParams{"message_id": messageId})
+		if err != nil || data == nil {
+			// 调用失败时不记录，下次再查询
+			done <- false
+			return
+		}
+		userId := data.Get("sender.user_id")
+		if !userId.Exists() {
+			userId = data.Get("user_id")
+		}
+		fromMe := userId.Exists() && userId.Int() == bot.selfId
+		if fromMe {
+			bot.sentMessages.add(id)
+		} else {
+			bot.otherMessages.add(id)
+		}
+		done <- fromMe
+	}()
+	select {
+	case fromMe := <-done:
+		return fromMe
+	case <-time.After(replyLookupTimeout):
+		return false
+	}
+}
+
+// 处理消息对bot的称呼。消息开头At了bot、以bot的昵称开头、或回复了bot的消息时，视为与bot有关；
+// 同时去除开头的At与昵称，使“@bot /help”、“小G，/help”与“/help”一样能匹配命令
+func (engine *Engine) detectToMe(ev I_Event) {
+	var base *MessageEvent
+	switch ev := ev.(type) {
+	case *PrivateMessageEvent:
+		base = &ev.MessageEvent
+	case *GroupMessageEvent:
+		base = &ev.MessageEvent
+	default:
+		return
+	}
+
+	msg := base.Message
+	if stripped, ok := msg.StripLeadingAt(base.SelfId); ok {
+		base.ToMe = true
+		msg = stripped
+	}
+	if stripped, ok := msg.stripLeadingNickname(engine.GetConfig().GetBaseConfig().Nickname); ok {
+		base.ToMe = true
+		msg = stripped
+	}
+	base.Message = msg
+
+	if !base.ToMe && engine.bot != nil {
+		if replyId, ok := msg.ReplyTo(); ok && engine.bot.isMessageFromMe(replyId) {
+			base.ToMe = true
+		}
+	}
+}
+
+// 昵称以英文字母或数字结尾时，其后不能紧跟英文字母或数字，以免“gbots”被当作以“gbot”开头。
+// 中文里常不加分隔，如“小G帮我查天气”，其余情况都视为分界
+func isNicknameBoundary(name, rest string) bool {
+	last, _ := utf8.DecodeLastRuneInString(name)
+	next, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || !isASCIIAlnum(last) || !isASCIIAlnum(next)
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// 去除开头的昵称以及其后的逗号、冒号与空白，回复消息段会被保留。其他标点可能是命令前缀，不做处理
+func (m Message) stripLeadingNickname(nicknames []string) (Message, bool) {
+	for i, seg := range m {
+		if seg.Type == "reply" {
+			continue
+		}
+		if !seg.IsText() {
+			return m, false
+		}
+		text := strings.TrimLeftFunc(fmt.Sprint(seg.Data["text"]), unicode.IsSpace)
+		if text == "" {
+			continue
+		}
+		for _, name := range nicknames {
+			if name == "" || !strings.HasPrefix(text, name) || !isNicknameBoundary(name, text[len(name):]) {
+				continue
+			}
+			rest := strings.TrimLeftFunc(text[len(name):], func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune(",，:：、", r)
+			})
+			ret := append(Message{}, m[:i]...)
+			if rest != "" {
+				ret = append(ret, MsgFactory.Text(rest))
+			}
+			return append(ret, m[i+1:]...).TrimSpace().mergeText(), true
+		}
+		return m, false
+	}
+	return m, false
+}
@@ -0,0 +1,70 @@
+package gonebot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func Test_DetectToMe(t *testing.T) {
+	engine := NewEngineWithProvider(&BaseConfig{Nickname: []string{"小G", "gbot", "小明"}}, &recordingProvider{})
+	defer engine.heartbeat.stop()
+	engine.bot.selfId = 1
+	// 记录一条bot发出的消息，ID为1
+	engine.bot.CallApi("send_group_msg", ApiParams{})
+
+	tests := []struct {
+		message string
+		toMe    bool
+		text    string
+	}{
+		{"[CQ:at,qq=1] /help", true, "/help"},
+		{"[CQ:at,qq=2] /help", false, "/help"},
+		{"小G，/help", true, "/help"},
+		{"gbot: 查天气", true, "查天气"},
+		{"小G!help", true, "!help"},
+		{"大G你好", false, "大G你好"},
+		{"gbots are cool", false, "gbots are cool"},
+		{"gbot", true, ""},
+		{"gbot2号", false, "gbot2号"},
+		{"小G帮我查天气", true, "帮我查天气"},
+		{"小明abc", true, "abc"},
+		{"gbot查天气", true, "查天气"},
+		{"[CQ:reply,id=1]谢谢", true, "谢谢"},
+		{"[CQ:reply,id=99]谢谢", false, "谢谢"},
+		{"[CQ:reply,id=99][CQ:at,qq=1] 小G 你好", true, "你好"},
+	}
+	for _, tt := range tests {
+		raw := fmt.Sprintf(`{"post_type": "message", "message_type": "group", "self_id": 1, "user_id": 2, "group_id": 3, "message": %q}`, tt.message)
+		ev := ConvertJsonObjectToEvent(gjson.Parse(raw))
+		engine.detectToMe(ev)
+		if ev.IsToMe() != tt.toMe || ev.ExtractPlainText() != tt.text {
+			t.Errorf("%s: ToMe应为%v，文本应为%q，实际为%v，%q", tt.message, tt.toMe, tt.text, ev.IsToMe(), ev.ExtractPlainText())
+		}
+	}
+}
+
+func Test_IsMessageFromMe(t *testing.T) {
+	provider := &recordingProvider{}
+	engine := NewEngineWithProvider(&BaseConfig{}, provider)
+	defer engine.heartbeat.stop()
+	engine.bot.selfId = 1
+
+	// 快速操作发出的消息没有返回ID，从协议端上报的自身消息中记录
+	sent := ConvertJsonObjectToEvent(gjson.Parse(`{"post_type": "message_sent", "message_type": "group", "self_id": 1, "user_id": 1, "group_id": 3, "message_id": 50, "message": "hi"}`))
+	engine.bot.recordSentEvent(sent)
+	if !engine.bot.isMessageFromMe(50) || len(provider.routes()) != 0 {
+		t.Errorf("上报过的自身消息不应再查询，实际调用：%v", provider.routes())
+	}
+
+	// 查询过的消息不再重复查询
+	for i := 0; i < 2; i++ {
+		if engine.bot.isMessageFromMe(60) {
+			t.Error("消息60不是bot发出的")
+		}
+	}
+	if routes := provider.routes(); len(routes) != 1 || routes[0] != "get_msg" {
+		t.Errorf("应只查询一次，实际调用：%v", routes)
+	}
+}
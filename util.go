@@ -1,9 +1,13 @@
 package gonebot
 
 import (
+	"encoding"
+	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -60,28 +64,37 @@ func isEventRelativeToBot(event I_Event) bool {
 
 	// 群聊消息，如果At了bot，则是
 	case *GroupMessageEvent:
-		myIdStr := strconv.FormatInt(myId, 10)
-		atSegs := event.Message.FilterByType("at")
-		for _, seg := range atSegs {
-			if seg.Data["qq"].(string) == myIdStr {
+		return event.Message.HasAtMe(myId)
+
+	// 自己发的消息
+	case *PrivateMessageSentEvent, *GroupMessageSentEvent:
+		return false
+
+	// 频道消息，At的是bot在频道中的ID
+	case *GuildMessageEvent:
+		for _, qq := range event.Message.AtTargets() {
+			if strconv.FormatInt(qq, 10) == event.SelfTinyId {
 				return true
 			}
 		}
 		return false
 
-	// 事件中有targetid字段的，则用targetid判断，否则用userid判断
+	// 事件中有targetid字段的，则用targetid判断，否则用userid判断。频道事件的ID为字符串，不参与判断
 	default:
 		if targetId, exist := getEventField(event, "TargetId"); exist {
-			return targetId.(int64) == myId
+			id, ok := targetId.(int64)
+			return ok && id == myId
 		}
 		if userId, exist := getEventField(event, "UserId"); exist {
-			return userId.(int64) == myId
+			id, ok := userId.(int64)
+			return ok && id == myId
 		}
 	}
 	return false
 }
 
-func mapToStruct(m map[string]interface{}, s interface{}) {
+// 将map中的值按字段名填充到结构体，s为结构体指针。所有字段的错误都会记录到errs中，path为当前结构体在配置中的路径
+func mapToStruct(m map[string]interface{}, s interface{}, path string, errs *ConfigErrors) {
 	sValue := reflect.ValueOf(s).Elem()
 	sType := sValue.Type()
 
@@ -105,7 +118,7 @@ func mapToStruct(m map[string]interface{}, s interface{}) {
 			}
 
 			if v, ok := m[name]; ok {
-				typeConvert(v, f)
+				typeConvert(v, f, joinConfigPath(path, name), errs)
 				break
 			}
 		}
@@ -128,46 +141,219 @@ func camelCaseToSnakeCase(s string) string {
 	return string(res)
 }
 
-func typeConvert(v interface{}, f reflect.Value) {
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// 将配置中的值v转换为f的类型并赋值，类型不符时将错误记录到errs中，f保持原值
+func typeConvert(v interface{}, f reflect.Value, path string, errs *ConfigErrors) {
+	fail := func(format string, args ...interface{}) {
+		errs.add(path, fmt.Errorf(format, args...))
+	}
+	if v == nil {
+		return
+	}
+
+	// 实现了encoding.TextUnmarshaler的类型（如MsgTemplate）从字符串载入
+	if s, ok := v.(string); ok && f.CanAddr() {
+		if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(s)); err != nil {
+				errs.add(path, err)
+			}
+			return
+		}
+	}
+	// 时长可以写成“1m30s”这样的字符串
+	if s, ok := v.(string); ok && f.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			fail("时长格式错误：%q", s)
+			return
+		}
+		f.SetInt(int64(d))
+		return
+	}
+
+	vv := reflect.ValueOf(v)
 	switch f.Kind() {
-	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
-		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint,
-		reflect.Float32, reflect.Float64:
-		f.Set(reflect.ValueOf(v).Convert(f.Type()))
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		n, ok := toInt64(vv)
+		if !ok {
+			fail("需要整数，实际为%s", describeConfigValue(v))
+		} else if f.OverflowInt(n) {
+			fail("%d超出了%s的范围", n, f.Type())
+		} else {
+			f.SetInt(n)
+		}
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		n, ok := toInt64(vv)
+		if !ok {
+			fail("需要整数，实际为%s", describeConfigValue(v))
+		} else if n < 0 || f.OverflowUint(uint64(n)) {
+			fail("%d超出了%s的范围", n, f.Type())
+		} else {
+			f.SetUint(uint64(n))
+		}
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat64(vv)
+		if !ok {
+			fail("需要数字，实际为%s", describeConfigValue(v))
+		} else {
+			f.SetFloat(n)
+		}
+
+	case reflect.Bool:
+		switch b := v.(type) {
+		case bool:
+			f.SetBool(b)
+		case string:
+			pb, err := strconv.ParseBool(b)
+			if err != nil {
+				fail("需要布尔值，实际为%s", describeConfigValue(v))
+				return
+			}
+			f.SetBool(pb)
+		default:
+			fail("需要布尔值，实际为%s", describeConfigValue(v))
+		}
+
+	case reflect.String:
+		switch vv.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+			fail("需要字符串，实际为%s", describeConfigValue(v))
+		default:
+			f.SetString(fmt.Sprint(v))
+		}
 
 	case reflect.Struct:
-		mapToStruct(v.(map[string]interface{}), f.Addr().Interface())
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			fail("需要对象，实际为%s", describeConfigValue(v))
+			return
+		}
+		mapToStruct(m, f.Addr().Interface(), path, errs)
 
 	case reflect.Ptr:
 		elemType := f.Type().Elem() // 获取指针指向的元素类型
 		nv := reflect.New(elemType) // 创建类型为elemType的零值
-		f.Set(nv)                   // 设置指针指向nv
-		typeConvert(v, f.Elem())
+		withConfigDefaults(nv.Elem(), path, errs)
+		before := len(*errs)
+		typeConvert(v, nv.Elem(), path, errs)
+		if len(*errs) == before {
+			f.Set(nv) // 设置指针指向nv
+		}
 
 	case reflect.Slice:
-		newSlice := reflect.MakeSlice(f.Type(), 0, 0)
-		vv := reflect.ValueOf(v)
+		if vv.Kind() != reflect.Slice && vv.Kind() != reflect.Array {
+			fail("需要列表，实际为%s", describeConfigValue(v))
+			return
+		}
+		newSlice := reflect.MakeSlice(f.Type(), 0, vv.Len())
 		for i := 0; i < vv.Len(); i++ {
 			nv := reflect.New(f.Type().Elem())
-			typeConvert(vv.Index(i).Interface(), nv.Elem())
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			withConfigDefaults(nv.Elem(), elemPath, errs)
+			typeConvert(vv.Index(i).Interface(), nv.Elem(), elemPath, errs)
 			newSlice = reflect.Append(newSlice, nv.Elem())
 		}
 		f.Set(newSlice)
 
 	case reflect.Map:
+		if vv.Kind() != reflect.Map {
+			fail("需要对象，实际为%s", describeConfigValue(v))
+			return
+		}
 		newMap := reflect.MakeMap(f.Type())
-		vv := reflect.ValueOf(v)
 		for _, k := range vv.MapKeys() {
+			key := reflect.New(f.Type().Key())
+			keyPath := joinConfigPath(path, fmt.Sprint(k.Interface()))
+			typeConvert(k.Interface(), key.Elem(), keyPath, errs)
 			nv := reflect.New(f.Type().Elem())
-			typeConvert(vv.MapIndex(k).Interface(), nv.Elem())
-			newMap.SetMapIndex(k, nv.Elem())
+			withConfigDefaults(nv.Elem(), keyPath, errs)
+			typeConvert(vv.MapIndex(k).Interface(), nv.Elem(), keyPath, errs)
+			newMap.SetMapIndex(key.Elem(), nv.Elem())
 		}
 		f.Set(newMap)
 
+	case reflect.Interface:
+		if !vv.Type().AssignableTo(f.Type()) {
+			fail("不能将%s赋值给%s", describeConfigValue(v), f.Type())
+			return
+		}
+		f.Set(vv)
+
 	default:
-		f.Set(reflect.ValueOf(v))
+		if !vv.Type().ConvertibleTo(f.Type()) {
+			fail("不能将%s转换为%s", describeConfigValue(v), f.Type())
+			return
+		}
+		f.Set(vv.Convert(f.Type()))
+	}
+}
+
+// 转换为整数，字符串会被解析，带小数部分的数字不能转换
+func toInt64(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		fl := v.Float()
+		if fl != math.Trunc(fl) || fl > math.MaxInt64 || fl < math.MinInt64 {
+			return 0, false
+		}
+		return int64(fl), true
+	case reflect.String:
+		n, err := strconv.ParseInt(strings.TrimSpace(v.String()), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// 转换为浮点数，字符串会被解析
+func toFloat64(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+		return n, err == nil
+	}
+	n, ok := toInt64(v)
+	return float64(n), ok
+}
+
+// 用于错误信息，如“字符串"abc"”
+func describeConfigValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("字符串%q", v)
+	case bool:
+		return fmt.Sprintf("布尔值%v", v)
+	case map[string]interface{}:
+		return "对象"
+	case []interface{}:
+		return "列表"
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("数字%v", v)
 	}
+	return fmt.Sprintf("%T类型的%v", v, v)
+}
 
+// 拼接配置项的路径，如obj.items
+func joinConfigPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
 }
 
 // 创建相同结构的新对象，返回指针